@@ -33,8 +33,18 @@ build() {
 build_all() {
   declare -A targets=(
     ["box"]="containerfiles/box.Dockerfile"
+    ["cc"]="containerfiles/cc.Dockerfile"
+    ["cc-sdk"]="containerfiles/cc-sdk.Dockerfile"
     ["dotnet"]="containerfiles/dotnet.Dockerfile"
+    ["dotnet-sdk"]="containerfiles/dotnet-sdk.Dockerfile"
+    ["go"]="containerfiles/go.Dockerfile"
+    ["go-sdk"]="containerfiles/go-sdk.Dockerfile"
+    ["java"]="containerfiles/java.Dockerfile"
+    ["java-sdk"]="containerfiles/java-sdk.Dockerfile"
+    ["node"]="containerfiles/node.Dockerfile"
     ["python"]="containerfiles/python.Dockerfile"
+    ["rust"]="containerfiles/rust.Dockerfile"
+    ["rust-sdk"]="containerfiles/rust-sdk.Dockerfile"
     ["engine"]="Dockerfile"
   )
 
@@ -47,8 +57,18 @@ build_all() {
 pull_all() {
   images=(
     "iklabib/kerat:box"
+    "iklabib/kerat:cc"
+    "iklabib/kerat:cc-sdk"
     "iklabib/kerat:dotnet"
+    "iklabib/kerat:dotnet-sdk"
+    "iklabib/kerat:go"
+    "iklabib/kerat:go-sdk"
+    "iklabib/kerat:java"
+    "iklabib/kerat:java-sdk"
+    "iklabib/kerat:node"
     "iklabib/kerat:python"
+    "iklabib/kerat:rust"
+    "iklabib/kerat:rust-sdk"
     "iklabib/kerat:engine"
   )
 
@@ -59,7 +79,7 @@ pull_all() {
 }
 
 if [[ $# -lt 1 ]]; then
-  echo "Usage: $0 {box|dotnet|python|engine|all} [ARCH]"
+  echo "Usage: $0 {box|cc|cc-sdk|dotnet|dotnet-sdk|go|go-sdk|java|java-sdk|node|python|rust|rust-sdk|engine|all} [ARCH]"
   echo "ARCH must be 'amd64', 'arm64', or 'all' for multi-arch builds."
   exit 1
 fi
@@ -73,12 +93,42 @@ case "$1" in
 "box")
   build "box" "containerfiles/box.Dockerfile" "$push"
   ;;
+"cc")
+  build "cc" "containerfiles/cc.Dockerfile" "$push"
+  ;;
+"cc-sdk")
+  build "cc-sdk" "containerfiles/cc-sdk.Dockerfile" "$push"
+  ;;
 "dotnet")
   build "dotnet" "containerfiles/dotnet.Dockerfile" "$push"
   ;;
+"dotnet-sdk")
+  build "dotnet-sdk" "containerfiles/dotnet-sdk.Dockerfile" "$push"
+  ;;
+"go")
+  build "go" "containerfiles/go.Dockerfile" "$push"
+  ;;
+"go-sdk")
+  build "go-sdk" "containerfiles/go-sdk.Dockerfile" "$push"
+  ;;
+"java")
+  build "java" "containerfiles/java.Dockerfile" "$push"
+  ;;
+"java-sdk")
+  build "java-sdk" "containerfiles/java-sdk.Dockerfile" "$push"
+  ;;
+"node")
+  build "node" "containerfiles/node.Dockerfile" "$push"
+  ;;
 "python")
   build "python" "containerfiles/python.Dockerfile" "$push"
   ;;
+"rust")
+  build "rust" "containerfiles/rust.Dockerfile" "$push"
+  ;;
+"rust-sdk")
+  build "rust-sdk" "containerfiles/rust-sdk.Dockerfile" "$push"
+  ;;
 "engine")
   build "engine" "Dockerfile" "$push"
   ;;
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"codeberg.org/iklabib/kerat/processor"
 	"codeberg.org/iklabib/kerat/server"
@@ -20,18 +25,53 @@ func main() {
 		log.Fatal(err)
 	}
 
-	httpServer := server.NewHTTPServer(processor, config.QueueCap)
+	token := os.Getenv("KERAT_ADMIN_TOKEN")
+	httpServer := server.NewHTTPServer(processor, config.QueueCap, config.JobRetention, token)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /submit", httpServer.HandleSubmission)
+	mux.HandleFunc("POST /submissions", httpServer.HandleSubmissionAsync)
+	mux.HandleFunc("GET /submissions/{id}", httpServer.HandleSubmissionStatus)
+	mux.HandleFunc("GET /submissions/{id}/events", httpServer.HandleSubmissionEvents)
+
+	mux.HandleFunc("POST /exercises/{id}", server.RequireToken(token, httpServer.HandleCreateExercise))
+	mux.HandleFunc("PUT /exercises/{id}", server.RequireToken(token, httpServer.HandleUpdateExercise))
+	mux.HandleFunc("GET /exercises/{id}", server.RequireToken(token, httpServer.HandleGetExercise))
+	mux.HandleFunc("GET /exercises/{id}/versions", server.RequireToken(token, httpServer.HandleExerciseVersions))
+	mux.HandleFunc("GET /exercises/{id}/versions/{version}", server.RequireToken(token, httpServer.HandleGetExercise))
+	mux.HandleFunc("DELETE /exercises/{id}", server.RequireToken(token, httpServer.HandleDeleteExercise))
+	mux.HandleFunc("GET /admin/caches", server.RequireToken(token, httpServer.HandleCacheStats))
+	mux.HandleFunc("DELETE /admin/caches", server.RequireToken(token, httpServer.HandlePurgeCaches))
+	mux.HandleFunc("DELETE /admin/caches/{id}", server.RequireToken(token, httpServer.HandlePurgeCache))
 
 	address := ":31415"
 	if host := os.Getenv("KERAT_HOST"); host != "" {
 		address = host
 	}
 
-	log.Printf("Server starting on %s\n", address)
-	if err := http.ListenAndServe(address, mux); err != nil {
-		log.Fatal(err)
+	srv := &http.Server{Addr: address, Handler: mux}
+	go func() {
+		log.Printf("Server starting on %s\n", address)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	log.Println("Server shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("shutdown error: %v\n", err)
 	}
+
+	// asynchronous submissions outlive their requests
+	if err := httpServer.Wait(shutdownCtx); err != nil {
+		log.Printf("shutdown error: %v\n", err)
+	}
+
+	processor.Close()
 }
@@ -11,7 +11,6 @@ import (
 func TarSources(files types.SourceCode) (bytes.Buffer, error) {
 	var buf bytes.Buffer
 	tw := tar.NewWriter(&buf)
-	defer tw.Close()
 
 	sources := append(files.Src, files.SrcTest...)
 
@@ -32,26 +31,8 @@ func TarSources(files types.SourceCode) (bytes.Buffer, error) {
 		}
 	}
 
-	return buf, nil
-}
-
-func TarBinary(filename string, bin []byte) (bytes.Buffer, error) {
-	var buf bytes.Buffer
-	tw := tar.NewWriter(&buf)
-	defer tw.Close()
-
-	header := &tar.Header{
-		Name:    filename,
-		Size:    int64(len(bin)),
-		Mode:    0644,
-		ModTime: time.Now(),
-	}
-
-	if err := tw.WriteHeader(header); err != nil {
-		return buf, err
-	}
-
-	if _, err := tw.Write(bin); err != nil {
+	// end of archive blocks, they must land in buf before it is copied out
+	if err := tw.Close(); err != nil {
 		return buf, err
 	}
 
@@ -1,6 +1,8 @@
 package processor
 
 import (
+	"fmt"
+	"maps"
 	"os"
 
 	"codeberg.org/iklabib/kerat/processor/types"
@@ -18,5 +20,134 @@ func LoadConfig(configPath string) (*types.Config, error) {
 		return nil, err
 	}
 
+	for i := range config.SubmissionConfigs {
+		setDefaults(&config.SubmissionConfigs[i])
+	}
+
+	if config.JobRetention == 0 {
+		config.JobRetention = defaultJobRetention
+	}
+
 	return &config, nil
 }
+
+// minutes, long enough for clients to come back polling
+const defaultJobRetention = 45
+
+// KiB, enough for harness results of a large test suite
+const defaultMaxOutput = 1024
+
+func setDefaults(config *types.SubmissionConfig) {
+	if config.Kind == "" {
+		config.Kind = types.KindInterpreted
+		if config.Build != nil {
+			config.Kind = types.KindCompiled
+		}
+	}
+
+	if config.Workdir == "" {
+		config.Workdir = "/workspace"
+	}
+
+	if config.ResultFormat == "" {
+		config.ResultFormat = types.ResultJSON
+	}
+
+	if config.Build != nil && config.Build.Workdir == "" {
+		config.Build.Workdir = "/workspace"
+	}
+
+	if config.MaxOutput == 0 {
+		config.MaxOutput = defaultMaxOutput
+	}
+
+	if config.Build != nil && config.Build.MaxOutput == 0 {
+		config.Build.MaxOutput = defaultMaxOutput
+	}
+}
+
+// validateSubmissionConfig makes sure a submission type can be graded
+// from its config alone
+func validateSubmissionConfig(config types.SubmissionConfig) error {
+	if config.ContainerImage == "" {
+		return fmt.Errorf("submission config %q: no container image", config.Id)
+	}
+
+	switch config.ResultFormat {
+	case types.ResultJSON, types.ResultExitCode, types.ResultStdio, types.ResultChecker, types.ResultInteractor:
+	default:
+		return fmt.Errorf("submission config %q: unknown result format %q", config.Id, config.ResultFormat)
+	}
+
+	// checkers and interactors run as is, there is no toolchain for them
+	isProgram := config.ResultFormat == types.ResultChecker || config.ResultFormat == types.ResultInteractor
+	if isProgram && config.Kind != types.KindInterpreted {
+		return fmt.Errorf("submission config %q: %s type has to be interpreted", config.Id, config.ResultFormat)
+	}
+
+	switch config.Kind {
+	case types.KindInterpreted:
+		if config.Build != nil {
+			return fmt.Errorf("submission config %q: interpreted type has build config", config.Id)
+		}
+	case types.KindCompiled:
+		if config.Build == nil {
+			return fmt.Errorf("submission config %q: compiled type has no build config", config.Id)
+		}
+		if config.Build.ContainerImage == "" {
+			return fmt.Errorf("submission config %q: no build container image", config.Id)
+		}
+		if config.Build.Artifact == "" {
+			return fmt.Errorf("submission config %q: no build artifact", config.Id)
+		}
+	default:
+		return fmt.Errorf("submission config %q: unknown kind %q", config.Id, config.Kind)
+	}
+
+	return nil
+}
+
+// OverrideConfig applies non-zero resource limits and build flags of override on top of base,
+// how the type is built and run (kind, images, entry points, environment, etc.) always come from base
+func OverrideConfig(base, override types.SubmissionConfig) types.SubmissionConfig {
+	config := base
+
+	if override.CPUPeriod != 0 {
+		config.CPUPeriod = override.CPUPeriod
+	}
+	if override.CPUQuota != 0 {
+		config.CPUQuota = override.CPUQuota
+	}
+	if override.MaxPids != 0 {
+		config.MaxPids = override.MaxPids
+	}
+	if override.MaxSwap != 0 {
+		config.MaxSwap = override.MaxSwap
+	}
+	if override.MaxMemory != 0 {
+		config.MaxMemory = override.MaxMemory
+	}
+	if override.Timeout != 0 {
+		config.Timeout = override.Timeout
+	}
+	if override.MaxOutput != 0 {
+		config.MaxOutput = override.MaxOutput
+	}
+	if override.CPUTimeLimit != 0 {
+		config.CPUTimeLimit = override.CPUTimeLimit
+	}
+	if override.TestTimeout != 0 {
+		config.TestTimeout = override.TestTimeout
+	}
+	if override.Sanitize {
+		config.Sanitize = true
+	}
+
+	if len(override.Ulimits) > 0 {
+		config.Ulimits = make(map[string]int64, len(base.Ulimits)+len(override.Ulimits))
+		maps.Copy(config.Ulimits, base.Ulimits)
+		maps.Copy(config.Ulimits, override.Ulimits)
+	}
+
+	return config
+}
@@ -0,0 +1,265 @@
+// Package containertest provides a scripted container engine for tests,
+// no engine config selects it
+package containertest
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"codeberg.org/iklabib/kerat/processor/container"
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+// FakeRun is a scripted outcome of Fake.Run and Fake.Execute
+type FakeRun struct {
+	Result  types.ContainerResult
+	Runtime types.Runtime
+	Files   map[string][]byte // files produced by the run, keyed by absolute path
+	Err     error
+}
+
+// Fake is an in-process engine that never touches a container runtime.
+// Runs are scripted per submission type and consumed in order,
+// an unscripted run fails so a test cannot pass by accident
+type Fake struct {
+	container.Registry
+	mu         sync.Mutex
+	seq        int
+	scripts    map[string][]FakeRun
+	containers map[string]*fakeContainer
+}
+
+type fakeContainer struct {
+	config  types.SubmissionConfig
+	files   map[string][]byte
+	started bool
+}
+
+func NewFake(config types.Config) (*Fake, error) {
+	registry, err := container.NewRegistry(config.SubmissionConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Fake{
+		Registry:   registry,
+		scripts:    make(map[string][]FakeRun),
+		containers: make(map[string]*fakeContainer),
+	}, nil
+}
+
+// Script queues results for the next runs of subType
+func (e *Fake) Script(subType string, runs ...FakeRun) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.scripts[subType] = append(e.scripts[subType], runs...)
+}
+
+// Files returns files copied to a container so far, keyed by absolute path
+func (e *Fake) Files(id string) map[string][]byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	files := make(map[string][]byte)
+	if c, ok := e.containers[id]; ok {
+		for k, v := range c.files {
+			files[k] = v
+		}
+	}
+
+	return files
+}
+
+// Containers returns ids of containers that are not removed yet
+func (e *Fake) Containers() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ids := []string{}
+	for k := range e.containers {
+		ids = append(ids, k)
+	}
+
+	return ids
+}
+
+func (e *Fake) Check() error {
+	return nil
+}
+
+func (e *Fake) Create(ctx context.Context, config types.SubmissionConfig) (string, error) {
+	if err := e.ValidateConfig(config); err != nil {
+		return "", err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.seq++
+	id := fmt.Sprintf("fake%012d", e.seq)
+	e.containers[id] = &fakeContainer{
+		config: config,
+		files:  make(map[string][]byte),
+	}
+
+	return id, nil
+}
+
+func (e *Fake) Copy(ctx context.Context, payload types.CopyPayload) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	c, ok := e.containers[payload.ContainerId]
+	if !ok {
+		return fmt.Errorf("no such container: %s", payload.ContainerId)
+	}
+
+	tr := tar.NewReader(payload.Content)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error reading tar: %w", err)
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("error reading tar: %w", err)
+		}
+		c.files[path.Join(payload.Dest, header.Name)] = content
+	}
+}
+
+// Run streams scripted tests to payload.OnTest before returning them, as harnesses do
+func (e *Fake) Run(ctx context.Context, payload types.RunPayload) (types.ContainerResult, error) {
+	run, err := e.start(ctx, payload.ContainerId)
+	if err != nil {
+		return types.ContainerResult{}, err
+	}
+
+	if payload.OnTest != nil && run.Err == nil {
+		for _, v := range run.Result.Output {
+			payload.OnTest(v)
+		}
+	}
+
+	return run.Result, run.Err
+}
+
+// Execute drains stdin, scripted stdout is written to the stream if there is one
+func (e *Fake) Execute(ctx context.Context, payload types.RunPayload) (types.Runtime, error) {
+	run, err := e.start(ctx, payload.ContainerId)
+	if err != nil {
+		return types.Runtime{}, err
+	}
+
+	if payload.Stdin != nil {
+		go io.Copy(io.Discard, payload.Stdin)
+	}
+
+	rt := run.Runtime
+	if payload.Stdout != nil {
+		payload.Stdout.Write(rt.Stdout)
+		rt.Stdout = nil
+	}
+
+	return rt, run.Err
+}
+
+// CopyFrom archives files of the container under path,
+// both copied in and produced by scripted run
+func (e *Fake) CopyFrom(ctx context.Context, containerId string, src string) (io.ReadCloser, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	c, ok := e.containers[containerId]
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", containerId)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	found := false
+
+	// entries are relative to parent of src, just like docker does
+	parent := path.Dir(path.Clean(src))
+	for name, content := range c.files {
+		if name != src && !strings.HasPrefix(name, src+"/") {
+			continue
+		}
+		found = true
+
+		rel := strings.TrimPrefix(name, parent+"/")
+		header := &tar.Header{Name: rel, Size: int64(len(content)), Mode: 0755}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return nil, err
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("could not find the file %s in container %s", src, containerId)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(&buf), nil
+}
+
+// start marks the container as started and pops next scripted run of its submission type
+func (e *Fake) start(ctx context.Context, id string) (FakeRun, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	c, ok := e.containers[id]
+	if !ok {
+		return FakeRun{}, fmt.Errorf("no such container: %s", id)
+	}
+
+	if c.started {
+		return FakeRun{}, fmt.Errorf("container %s is already started", id)
+	}
+	c.started = true
+
+	if err := ctx.Err(); err != nil {
+		return FakeRun{}, err
+	}
+
+	subType := c.config.Id
+	runs := e.scripts[subType]
+	if len(runs) == 0 {
+		return FakeRun{}, fmt.Errorf("no scripted run left for %q", subType)
+	}
+	e.scripts[subType] = runs[1:]
+
+	run := runs[0]
+	for k, v := range run.Files {
+		c.files[k] = v
+	}
+
+	return run, nil
+}
+
+func (e *Fake) Remove(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.containers[id]; !ok {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	delete(e.containers, id)
+
+	return nil
+}
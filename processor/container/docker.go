@@ -0,0 +1,416 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// Docker runs submissions through Docker Engine API
+type Docker struct {
+	Registry
+	client  *client.Client
+	runtime string
+}
+
+func NewDocker(config types.Config) (*Docker, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed start container engine")
+	}
+
+	return newDocker(cli, config)
+}
+
+func newDocker(cli *client.Client, config types.Config) (*Docker, error) {
+	if config.Runtime == "" {
+		config.Runtime = "runc"
+	}
+
+	registry, err := NewRegistry(config.SubmissionConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &Docker{
+		Registry: registry,
+		client:   cli,
+		runtime:  config.Runtime,
+	}
+
+	return engine, nil
+}
+
+func (e *Docker) buildHostConfig(config types.SubmissionConfig) (container.HostConfig, error) {
+	resources := container.Resources{
+		Memory:     config.MaxMemory * 1024 * 1024,
+		CPUPeriod:  config.CPUPeriod,
+		CPUQuota:   config.CPUQuota,
+		MemorySwap: config.MaxSwap * 1024 * 1024,
+		PidsLimit:  &config.MaxPids,
+	}
+
+	hostConfig := container.HostConfig{
+		AutoRemove: false,
+		// output is read through attach, nothing is kept on the host
+		LogConfig: container.LogConfig{Type: "none"},
+		Resources: resources,
+		Runtime:   e.runtime,
+		SecurityOpt: []string{
+			"no-new-privileges:true",
+		},
+	}
+
+	ulimits, err := parseUlimits(config.Ulimits)
+	if err != nil {
+		return hostConfig, err
+	}
+
+	// SIGXCPU at the soft limit, RLIMIT_CPU is per process so cgroup usage is watched while running as well
+	if config.CPUTimeLimit > 0 {
+		ulimits = slices.DeleteFunc(ulimits, func(u *container.Ulimit) bool { return u.Name == "cpu" })
+		ulimits = append(ulimits, &container.Ulimit{Name: "cpu", Soft: config.CPUTimeLimit, Hard: config.CPUTimeLimit + 1})
+	}
+	hostConfig.Ulimits = ulimits
+
+	return hostConfig, nil
+}
+
+func (e *Docker) Check() error {
+	_, err := e.client.Ping(context.Background())
+	return err
+}
+
+func (e *Docker) Create(ctx context.Context, submissionConfig types.SubmissionConfig) (string, error) {
+	hostConfig, err := e.buildHostConfig(submissionConfig)
+	if err != nil {
+		return "", err
+	}
+
+	containerConfig := container.Config{
+		Hostname:        "box",
+		Domainname:      "box",
+		NetworkDisabled: true,
+		Image:           submissionConfig.ContainerImage,
+	}
+
+	entryPoint := submissionConfig.EntryPoint
+	if len(entryPoint) > 0 {
+		containerConfig.Entrypoint = entryPoint
+	}
+
+	for k, v := range submissionConfig.Env {
+		containerConfig.Env = append(containerConfig.Env, k+"="+v)
+	}
+
+	// harness times each test on its own, timeout still bounds the whole run
+	if submissionConfig.TestTimeout > 0 {
+		containerConfig.Env = append(containerConfig.Env, "KERAT_TEST_TIMEOUT="+strconv.Itoa(submissionConfig.TestTimeout))
+	}
+
+	// lets a harness with costly per test startup stop before being killed
+	if submissionConfig.Timeout > 0 {
+		containerConfig.Env = append(containerConfig.Env, "KERAT_TIMEOUT="+strconv.Itoa(submissionConfig.Timeout))
+	}
+
+	// stdin is closed once the input is written, program sees EOF
+	format := submissionConfig.ResultFormat
+	if format == types.ResultStdio || format == types.ResultInteractor {
+		containerConfig.OpenStdin = true
+		containerConfig.StdinOnce = true
+		containerConfig.AttachStdin = true
+	}
+
+	resp, err := e.client.ContainerCreate(context.Background(), &containerConfig, &hostConfig, nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("error create container: %w", err)
+	}
+
+	return resp.ID, nil
+}
+
+func (e *Docker) Copy(ctx context.Context, payload types.CopyPayload) error {
+	opt := container.CopyToContainerOptions{}
+	return e.client.CopyToContainer(ctx, payload.ContainerId, payload.Dest, payload.Content, opt)
+}
+
+// Execute starts the container and collects its raw output once it stops,
+// output is read while it runs and the container is killed once it exceeds max_output
+func (e *Docker) Execute(ctx context.Context, payload types.RunPayload) (types.Runtime, error) {
+	timeout := payload.Config.Timeout
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer timeoutCancel()
+
+	var res types.Runtime
+
+	// attach before start, otherwise fast programs may exit before reading input
+	opt := container.AttachOptions{Stream: true, Stdin: payload.Stdin != nil, Stdout: true, Stderr: true}
+	attached, err := e.client.ContainerAttach(ctx, payload.ContainerId, opt)
+	if err != nil {
+		return res, fmt.Errorf("error attach container: %w", err)
+	}
+	defer attached.Close()
+
+	if err := e.client.ContainerStart(ctx, payload.ContainerId, container.StartOptions{}); err != nil {
+		return res, fmt.Errorf("error start container: %w", err)
+	}
+
+	if payload.Stdin != nil {
+		// program may exit without reading everything, it is judged by its output
+		go func() {
+			io.Copy(attached.Conn, payload.Stdin)
+			attached.CloseWrite()
+		}()
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	limit := newOutputLimit(payload.Config.MaxOutput * 1024)
+
+	var stdoutWriter io.Writer = &stdout
+	if payload.Stdout != nil {
+		stdoutWriter = payload.Stdout
+	}
+
+	streamed := make(chan error, 1)
+	go func() {
+		_, err := StdCopy(limit.Writer(stdoutWriter), limit.Writer(&stderr), attached.Reader)
+		streamed <- err
+	}()
+
+	cpuLimit := uint64(payload.Config.CPUTimeLimit) * uint64(time.Second)
+	metricsCh := make(chan types.Metrics, 1)
+	monitorErrCh := make(chan error, 1)
+	cpuExceeded := make(chan struct{})
+	statCtx, statCancel := context.WithCancel(ctx)
+	go e.monitorStat(statCtx, payload.ContainerId, cpuLimit, metricsCh, monitorErrCh, cpuExceeded)
+	defer statCancel()
+
+	var exitCode int64 = 0
+	var cpuKilled bool
+	exceeded := limit.exceeded
+	statusCh, errCh := e.client.ContainerWait(timeoutCtx, payload.ContainerId, container.WaitConditionNotRunning)
+wait:
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return res, ErrTimeout
+			}
+			return res, ctx.Err()
+
+		case <-exceeded:
+			// nothing it prints is kept anymore, it may have stopped meanwhile
+			e.client.ContainerKill(ctx, payload.ContainerId, "SIGKILL")
+			exceeded = nil
+
+		case <-cpuExceeded:
+			e.client.ContainerKill(ctx, payload.ContainerId, "SIGKILL")
+			cpuKilled = true
+			cpuExceeded = nil
+
+		case err := <-errCh:
+			if errors.Is(err, context.DeadlineExceeded) {
+				return res, ErrTimeout
+			} else if err != nil {
+				return res, fmt.Errorf("error waiting for container: %w", err)
+			}
+			break wait
+
+		case err := <-monitorErrCh:
+			return res, fmt.Errorf("monitoring error: %w", err)
+
+		case containerStat := <-statusCh:
+			exitCode = containerStat.StatusCode
+			if containerStat.Error != nil {
+				return res, fmt.Errorf("container %s exited with status code %d error message: %s", payload.ContainerId[:8], containerStat.StatusCode, containerStat.Error.Message)
+			}
+			break wait
+		}
+	}
+
+	// container should not running at this point
+	deadline, _ := timeoutCtx.Deadline()
+	wallTime := time.Since(deadline.Add(-time.Duration(timeout) * time.Second)).Seconds()
+
+	statCancel()
+
+	// stream ends once the container stops
+	select {
+	case err := <-streamed:
+		if err != nil {
+			return res, fmt.Errorf("error reading container output: %w", err)
+		}
+	case <-ctx.Done():
+		return res, ctx.Err()
+	}
+
+	metrics := <-metricsCh
+	metrics.ExitCode = exitCode
+	metrics.WallTime = wallTime
+	metrics.OutputTruncated = limit.Exceeded()
+
+	// 152 is SIGXCPU sent at the RLIMIT_CPU soft limit
+	metrics.CPUTimeExceeded = cpuLimit > 0 && (cpuKilled || exitCode == 152 || metrics.CpuTime > cpuLimit)
+
+	metrics.OOMKilled, err = e.oomKilled(ctx, payload.ContainerId, metrics, payload.Config.MaxMemory)
+	if err != nil {
+		return res, fmt.Errorf("error inspecting container: %w", err)
+	}
+
+	if metrics.OutputTruncated {
+		fmt.Fprintf(&stderr, "\n[output exceeded %d KiB and was truncated]\n", payload.Config.MaxOutput)
+	}
+
+	res.Stdout = stdout.Bytes()
+	res.Stderr = stderr.Bytes()
+	res.Metrics = metrics
+
+	return res, nil
+}
+
+// oomKilled tells whether the container was killed for exceeding its memory limit (MiB).
+// Not every runtime reports it (e.g. gVisor), SIGKILL with peak memory close
+// to the limit is taken as such as well
+func (e *Docker) oomKilled(ctx context.Context, id string, metrics types.Metrics, limit int64) (bool, error) {
+	inspect, err := e.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	if inspect.State != nil && inspect.State.OOMKilled {
+		return true, nil
+	}
+
+	limitBytes := uint64(limit) * 1024 * 1024
+	return limit > 0 && metrics.ExitCode == 137 && metrics.Memory >= limitBytes*95/100, nil
+}
+
+// Run executes the container and decodes test results reported by the harness,
+// tests streamed while it runs go to payload.OnTest
+func (e *Docker) Run(ctx context.Context, payload types.RunPayload) (types.ContainerResult, error) {
+	var res types.ContainerResult
+
+	stream := newResultStream(payload.OnTest)
+	payload.Stdout = stream
+	rt, err := e.Execute(ctx, payload)
+	stream.Close()
+	if err != nil {
+		return res, err
+	}
+
+	// harness output is cut somewhere, there is nothing to decode
+	if rt.Metrics.OutputTruncated {
+		res.Message = "output limit exceeded"
+		res.Output = []types.TestResult{}
+		res.Metrics = rt.Metrics
+
+		return res, nil
+	}
+
+	if rt.Metrics.ExitCode != 0 {
+		res.Message = string(rt.Stderr)
+		res.Output = []types.TestResult{}
+		res.Metrics = rt.Metrics
+
+		return res, nil
+	}
+
+	if err := json.Unmarshal(stream.Aggregate(), &res); err != nil {
+		return res, fmt.Errorf("%w: %v", ErrHarness, err)
+	}
+
+	res.Metrics = rt.Metrics
+	return res, nil
+}
+
+// CopyFrom returns content of path inside the container as TAR
+func (e *Docker) CopyFrom(ctx context.Context, containerId string, path string) (io.ReadCloser, error) {
+	content, _, err := e.client.CopyFromContainer(ctx, containerId, path)
+	return content, err
+}
+
+func (e *Docker) Stat(id string) (container.Stats, error) {
+	var statsResp container.StatsResponse
+	res, err := e.client.ContainerStats(context.Background(), id, false)
+	if err != nil {
+		return statsResp.Stats, err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(&statsResp); err != nil {
+		return statsResp.Stats, fmt.Errorf("failed to decode stats: %w", err)
+	}
+
+	return statsResp.Stats, err
+}
+
+// monitorStat records CPU usage and peak memory, cpuExceeded is closed once usage goes past cpuLimit (ns)
+func (e *Docker) monitorStat(ctx context.Context, id string, cpuLimit uint64, metricsCh chan<- types.Metrics, errCh chan<- error, cpuExceeded chan<- struct{}) {
+	defer close(metricsCh)
+
+	res, err := e.client.ContainerStats(ctx, id, true)
+	if err != nil {
+		errCh <- fmt.Errorf("failed to get stats stream: %w", err)
+		return
+	}
+	defer res.Body.Close()
+
+	decoder := json.NewDecoder(res.Body)
+
+	var cpu uint64 = 0
+	var peakMem uint64 = 0
+
+	defer func() {
+		metricsCh <- types.Metrics{
+			CpuTime: cpu,
+			Memory:  peakMem,
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			var statsResp container.StatsResponse
+			if err := decoder.Decode(&statsResp); err != nil {
+				errCh <- fmt.Errorf("failed to decode stats: %w", err)
+				return
+			}
+
+			stats := statsResp.Stats
+			cpu = stats.CPUStats.CPUUsage.TotalUsage
+			if cpuLimit > 0 && cpu > cpuLimit && cpuExceeded != nil {
+				close(cpuExceeded)
+				cpuExceeded = nil
+			}
+			usage := stats.MemoryStats.Usage
+			if usage > peakMem {
+				peakMem = usage
+			}
+		}
+	}
+}
+
+func (e *Docker) Remove(id string) error {
+	return e.client.ContainerRemove(context.Background(), id, container.RemoveOptions{Force: true})
+}
+
+func (e *Docker) Stop(id string) error {
+	return e.client.ContainerStop(context.Background(), id, container.StopOptions{})
+}
+
+func (e *Docker) Kill(id string) error {
+	return e.client.ContainerKill(context.Background(), id, "SIGKILL")
+}
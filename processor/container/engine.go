@@ -0,0 +1,108 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+	"github.com/docker/go-units"
+)
+
+var (
+	ErrTimeout = errors.New("runtime timeout")
+	ErrHarness = errors.New("malformed harness output")
+)
+
+// Engine creates and runs submission containers
+type Engine interface {
+	Check() error
+	IsSupported(subType string) bool
+	SubmissionConfig(subType string) (types.SubmissionConfig, bool)
+	ValidateConfig(config types.SubmissionConfig) error
+	Create(ctx context.Context, config types.SubmissionConfig) (string, error)
+	Copy(ctx context.Context, payload types.CopyPayload) error
+	Run(ctx context.Context, payload types.RunPayload) (types.ContainerResult, error)
+	Execute(ctx context.Context, payload types.RunPayload) (types.Runtime, error)
+	CopyFrom(ctx context.Context, containerId string, path string) (io.ReadCloser, error)
+	Remove(id string) error
+}
+
+// NewEngine picks container engine by "engine" config key, defaults to docker
+func NewEngine(config types.Config) (Engine, error) {
+	switch config.Engine {
+	case "", "docker":
+		engine, err := NewDocker(config)
+		if err != nil {
+			return nil, err
+		}
+		return engine, nil
+
+	case "podman":
+		engine, err := NewPodman(config)
+		if err != nil {
+			return nil, err
+		}
+		return engine, nil
+	}
+
+	return nil, fmt.Errorf("unsupported container engine %q", config.Engine)
+}
+
+// Registry holds submission types every engine shares
+type Registry map[string]types.SubmissionConfig
+
+func NewRegistry(configs []types.SubmissionConfig) (Registry, error) {
+	registry := make(Registry)
+	for _, v := range configs {
+		if _, err := parseUlimits(v.Ulimits); err != nil {
+			return nil, fmt.Errorf("submission config %q: %w", v.Id, err)
+		}
+
+		if v.Build != nil {
+			if _, err := parseUlimits(v.Build.Ulimits); err != nil {
+				return nil, fmt.Errorf("submission config %q build: %w", v.Id, err)
+			}
+		}
+		registry[v.Id] = v
+	}
+
+	return registry, nil
+}
+
+func (c Registry) IsSupported(subType string) bool {
+	_, ok := c[subType]
+	return ok
+}
+
+func (c Registry) SubmissionConfig(subType string) (types.SubmissionConfig, bool) {
+	config, ok := c[subType]
+	return config, ok
+}
+
+// ValidateConfig reports whether a container can be created with config
+func (c Registry) ValidateConfig(config types.SubmissionConfig) error {
+	_, err := parseUlimits(config.Ulimits)
+	return err
+}
+
+// posible values
+// core, cpu, data, fsize, locks,
+// memlock, msgqueue, nice, nofile,
+// nproc, rss, rtprio, rttime,
+// sigpending, stack
+func parseUlimits(ulimits map[string]int64) ([]*units.Ulimit, error) {
+	parsed := []*units.Ulimit{}
+	for k, v := range ulimits {
+		ulim, err := units.ParseUlimit(k)
+		if err != nil {
+			return parsed, fmt.Errorf("failed to parse ulimit %q", k)
+		}
+		ulim.Soft = v
+		ulim.Hard = -1
+		parsed = append(parsed, ulim)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,72 @@
+package container
+
+import (
+	"io"
+	"sync"
+)
+
+// outputLimit is shared by writers of every stream of a container, once the
+// combined limit is reached the rest is discarded and exceeded is closed
+type outputLimit struct {
+	mu       sync.Mutex
+	left     int64
+	exceeded chan struct{}
+}
+
+// zero limit is unbounded
+func newOutputLimit(limit int64) *outputLimit {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	return &outputLimit{left: limit, exceeded: make(chan struct{})}
+}
+
+func (l *outputLimit) Writer(w io.Writer) io.Writer {
+	return &limitedWriter{limit: l, w: w}
+}
+
+func (l *outputLimit) Exceeded() bool {
+	select {
+	case <-l.exceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// take reserves up to n bytes
+func (l *outputLimit) take(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.left < 0 {
+		return n
+	}
+
+	taken := int(min(int64(n), l.left))
+	l.left -= int64(taken)
+
+	if taken < n && !l.Exceeded() {
+		close(l.exceeded)
+	}
+
+	return taken
+}
+
+type limitedWriter struct {
+	limit *outputLimit
+	w     io.Writer
+}
+
+// never fails for exceeding the limit, the stream is drained so the container does not block
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	n := w.limit.take(len(p))
+	if n > 0 {
+		if _, err := w.w.Write(p[:n]); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,61 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+	"github.com/docker/docker/client"
+)
+
+// Podman runs submissions through Docker compatible endpoints of Podman REST API,
+// container handling is shared with Docker
+type Podman struct {
+	*Docker
+}
+
+func NewPodman(config types.Config) (*Podman, error) {
+	cli, err := client.NewClientWithOpts(client.WithHost(podmanHost()), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed start container engine")
+	}
+
+	docker, err := newDocker(cli, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Podman{Docker: docker}, nil
+}
+
+// Check makes sure the socket is served by Podman instead of Docker
+func (e *Podman) Check() error {
+	version, err := e.client.ServerVersion(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, v := range version.Components {
+		if v.Name == "Podman Engine" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s is not a podman socket", e.client.DaemonHost())
+}
+
+// CONTAINER_HOST is honored like podman-remote does, only unix socket is supported.
+// Otherwise rootless socket is preferred for non-root user
+func podmanHost() string {
+	if host := os.Getenv("CONTAINER_HOST"); host != "" {
+		return host
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && os.Getuid() != 0 {
+		return "unix://" + filepath.Join(dir, "podman", "podman.sock")
+	}
+
+	return "unix:///run/podman/podman.sock"
+}
@@ -0,0 +1,106 @@
+package container
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+// Pool keeps pre-created, not yet started containers per submission type
+// and hands them out on Create. Only containers of the unmodified submission
+// config are pooled, exercise overrides always get a fresh container
+type Pool struct {
+	Engine
+	idle   map[string]chan string
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// retry delay when the engine fails to create a container
+const poolBackoff = 5 * time.Second
+
+func NewPool(engine Engine, configs []types.SubmissionConfig) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := &Pool{
+		Engine: engine,
+		idle:   make(map[string]chan string),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, v := range configs {
+		if v.PoolSize <= 0 {
+			continue
+		}
+
+		pool.idle[v.Id] = make(chan string, v.PoolSize)
+		pool.wg.Add(1)
+		go pool.replenish(v)
+	}
+
+	return pool
+}
+
+func (p *Pool) Create(ctx context.Context, config types.SubmissionConfig) (string, error) {
+	base, _ := p.SubmissionConfig(config.Id)
+	if idle, ok := p.idle[config.Id]; ok && reflect.DeepEqual(base, config) {
+		select {
+		case id, ok := <-idle:
+			// closed once the pool is drained
+			if ok {
+				return id, nil
+			}
+		default:
+			// pool is exhausted, do not wait for replenishment
+		}
+	}
+
+	return p.Engine.Create(ctx, config)
+}
+
+// Close stops replenishment and removes idle containers
+func (p *Pool) Close() {
+	p.cancel()
+	p.wg.Wait()
+
+	for _, idle := range p.idle {
+		close(idle)
+		for id := range idle {
+			if err := p.Engine.Remove(id); err != nil {
+				log.Printf("[pool] failed to remove container %s: %v\n", id[:8], err)
+			}
+		}
+	}
+}
+
+func (p *Pool) replenish(config types.SubmissionConfig) {
+	defer p.wg.Done()
+	idle := p.idle[config.Id]
+
+	for {
+		id, err := p.Engine.Create(p.ctx, config)
+		if err != nil {
+			log.Printf("[pool] failed to create %s container: %v\n", config.Id, err)
+
+			select {
+			case <-time.After(poolBackoff):
+				continue
+			case <-p.ctx.Done():
+				return
+			}
+		}
+
+		// blocks until a container is taken out of a full pool
+		select {
+		case idle <- id:
+		case <-p.ctx.Done():
+			p.Engine.Remove(id)
+			return
+		}
+	}
+}
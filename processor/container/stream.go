@@ -0,0 +1,86 @@
+package container
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+// resultStream splits harness stdout into lines as it is written. A line of
+// {"test": {...}} is a test that just completed and is handed to onTest,
+// everything else makes up the aggregate ContainerResult printed at the end
+type resultStream struct {
+	mu        sync.Mutex
+	onTest    func(types.TestResult)
+	line      []byte // not terminated yet
+	aggregate bytes.Buffer
+	closed    bool
+}
+
+// streamed test line, see resultStream
+type streamedTest struct {
+	Test *types.TestResult `json:"test"`
+}
+
+func newResultStream(onTest func(types.TestResult)) *resultStream {
+	return &resultStream{onTest: onTest}
+}
+
+func (s *resultStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.line = append(s.line, p...)
+	for {
+		idx := bytes.IndexByte(s.line, '\n')
+		if idx < 0 {
+			break
+		}
+
+		s.parse(s.line[:idx+1])
+		s.line = s.line[idx+1:]
+	}
+
+	return len(p), nil
+}
+
+// Close parses what is left, onTest is not called anymore afterwards
+// even when a stopped container is still being drained
+func (s *resultStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.line) > 0 {
+		s.parse(s.line)
+		s.line = nil
+	}
+	s.closed = true
+}
+
+// Aggregate is stdout without streamed test lines
+func (s *resultStream) Aggregate() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.aggregate.Bytes()
+}
+
+// caller must hold the lock
+func (s *resultStream) parse(line []byte) {
+	if s.closed {
+		return
+	}
+
+	var streamed streamedTest
+	trimmed := bytes.TrimSpace(line)
+	if bytes.HasPrefix(trimmed, []byte(`{"test":`)) && json.Unmarshal(trimmed, &streamed) == nil && streamed.Test != nil {
+		if s.onTest != nil {
+			s.onTest(*streamed.Test)
+		}
+		return
+	}
+
+	s.aggregate.Write(line)
+}
@@ -0,0 +1,61 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+func TestResultStream(t *testing.T) {
+	var tests []string
+	stream := newResultStream(func(test types.TestResult) {
+		tests = append(tests, test.Name)
+	})
+
+	// lines are split across writes as they come from the container
+	fmt.Fprint(stream, `{"test": {"passed": true, "name": "test_a"}}`+"\n"+`{"test": {"pass`)
+	if len(tests) != 1 {
+		t.Fatalf("got %v before the second line is complete", tests)
+	}
+
+	fmt.Fprint(stream, `ed": false, "name": "test_b"}}`+"\n")
+	fmt.Fprint(stream, `{"success": false, "message": "", "output": [{"name": "test_a"}, {"name": "test_b"}]}`)
+	stream.Close()
+
+	if len(tests) != 2 || tests[1] != "test_b" {
+		t.Errorf("streamed %v", tests)
+	}
+
+	var res types.ContainerResult
+	if err := json.Unmarshal(stream.Aggregate(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Output) != 2 {
+		t.Errorf("aggregate has %d tests, want 2", len(res.Output))
+	}
+
+	fmt.Fprint(stream, `{"test": {"passed": true, "name": "test_c"}}`+"\n")
+	if len(tests) != 2 {
+		t.Errorf("streamed %v after close", tests)
+	}
+}
+
+func TestResultStreamWithoutTests(t *testing.T) {
+	stream := newResultStream(nil)
+
+	// harness printing only the aggregate, across lines
+	fmt.Fprint(stream, "{\"success\": true,\n\"message\": \"\", \"output\": []}\n")
+	stream.Close()
+
+	var res types.ContainerResult
+	if err := json.Unmarshal(stream.Aggregate(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Success {
+		t.Errorf("unexpected aggregate %s", stream.Aggregate())
+	}
+}
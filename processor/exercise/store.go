@@ -0,0 +1,438 @@
+package exercise
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+	"codeberg.org/iklabib/kerat/util"
+	"github.com/goccy/go-yaml"
+)
+
+var (
+	ErrNotFound  = errors.New("exercise not found")
+	ErrExists    = errors.New("exercise already exists")
+	ErrInvalidId = errors.New("invalid exercise id")
+)
+
+var validId = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Store reads and writes exercises from a directory, every update is
+// written as a new immutable version, laid out as
+//
+//	<root>/<exercise id>/<version>/exercise.yaml
+//	<root>/<exercise id>/<version>/tests/
+//	<root>/<exercise id>/<version>/templates/
+//	<root>/<exercise id>/<version>/solution/
+//	<root>/<exercise id>/<version>/cases/<n>.{in,out,yaml}
+//	<root>/<exercise id>/<version>/checker/
+//	<root>/<exercise id>/<version>/interactor/
+//
+// deleted exercise is only marked as such, so its versions stay
+// around for past submissions
+type Store struct {
+	root string
+	mu   sync.Mutex
+}
+
+func NewStore(root string) *Store {
+	return &Store{root: root}
+}
+
+// Load returns latest version of the exercise
+func (s *Store) Load(id string) (types.Exercise, error) {
+	if !validId.MatchString(id) {
+		return types.Exercise{}, fmt.Errorf("%w %q", ErrInvalidId, id)
+	}
+
+	if s.isDeleted(id) {
+		return types.Exercise{}, ErrNotFound
+	}
+
+	version, err := s.latestVersion(id)
+	if err != nil {
+		return types.Exercise{}, err
+	}
+
+	if version == 0 {
+		return types.Exercise{}, ErrNotFound
+	}
+
+	return s.readVersion(id, version)
+}
+
+// LoadVersion returns exact version of the exercise even if it is deleted
+func (s *Store) LoadVersion(id string, version int) (types.Exercise, error) {
+	if !validId.MatchString(id) {
+		return types.Exercise{}, fmt.Errorf("%w %q", ErrInvalidId, id)
+	}
+
+	if util.IsNotExist(s.versionDir(id, version)) {
+		return types.Exercise{}, ErrNotFound
+	}
+
+	return s.readVersion(id, version)
+}
+
+// LoadLiveVersion returns exact version of the exercise unless it is deleted
+func (s *Store) LoadLiveVersion(id string, version int) (types.Exercise, error) {
+	if !validId.MatchString(id) {
+		return types.Exercise{}, fmt.Errorf("%w %q", ErrInvalidId, id)
+	}
+
+	if s.isDeleted(id) {
+		return types.Exercise{}, ErrNotFound
+	}
+
+	return s.LoadVersion(id, version)
+}
+
+// Create writes first version of a new exercise,
+// a deleted exercise can be created again and continues its versioning
+func (s *Store) Create(exercise types.Exercise) (types.Exercise, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !validId.MatchString(exercise.Id) {
+		return exercise, fmt.Errorf("%w %q", ErrInvalidId, exercise.Id)
+	}
+
+	latest, err := s.latestVersion(exercise.Id)
+	if err != nil {
+		return exercise, err
+	}
+
+	if latest > 0 && !s.isDeleted(exercise.Id) {
+		return exercise, ErrExists
+	}
+
+	exercise.Version = latest + 1
+	if err := s.writeVersion(exercise); err != nil {
+		return exercise, err
+	}
+
+	if err := os.Remove(s.deletedMark(exercise.Id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return exercise, err
+	}
+
+	return exercise, nil
+}
+
+// Update writes a new version of an existing exercise
+func (s *Store) Update(exercise types.Exercise) (types.Exercise, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !validId.MatchString(exercise.Id) {
+		return exercise, fmt.Errorf("%w %q", ErrInvalidId, exercise.Id)
+	}
+
+	latest, err := s.latestVersion(exercise.Id)
+	if err != nil {
+		return exercise, err
+	}
+
+	if latest == 0 || s.isDeleted(exercise.Id) {
+		return exercise, ErrNotFound
+	}
+
+	exercise.Version = latest + 1
+	if err := s.writeVersion(exercise); err != nil {
+		return exercise, err
+	}
+
+	return exercise, nil
+}
+
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !validId.MatchString(id) {
+		return fmt.Errorf("%w %q", ErrInvalidId, id)
+	}
+
+	latest, err := s.latestVersion(id)
+	if err != nil {
+		return err
+	}
+
+	if latest == 0 || s.isDeleted(id) {
+		return ErrNotFound
+	}
+
+	return os.WriteFile(s.deletedMark(id), []byte{}, 0644)
+}
+
+// Versions lists available versions in ascending order
+func (s *Store) Versions(id string) ([]int, error) {
+	versions := []int{}
+	if !validId.MatchString(id) {
+		return versions, fmt.Errorf("%w %q", ErrInvalidId, id)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.root, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return versions, nil
+	} else if err != nil {
+		return versions, err
+	}
+
+	for _, v := range entries {
+		version, err := strconv.Atoi(v.Name())
+		if err != nil || !v.IsDir() || version < 1 {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	// ReadDir sorts by name, not by number
+	slices.Sort(versions)
+
+	return versions, nil
+}
+
+func (s *Store) latestVersion(id string) (int, error) {
+	versions, err := s.Versions(id)
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+
+	return versions[len(versions)-1], nil
+}
+
+func (s *Store) readVersion(id string, version int) (types.Exercise, error) {
+	var exercise types.Exercise
+	dir := s.versionDir(id, version)
+
+	content, err := os.ReadFile(filepath.Join(dir, "exercise.yaml"))
+	if err != nil {
+		return exercise, fmt.Errorf("failed to read exercise: %w", err)
+	}
+
+	if err := yaml.Unmarshal(content, &exercise); err != nil {
+		return exercise, fmt.Errorf("failed to parse exercise: %w", err)
+	}
+	exercise.Id = id
+	exercise.Version = version
+
+	exercise.Tests, err = readFiles(filepath.Join(dir, "tests"))
+	if err != nil {
+		return exercise, fmt.Errorf("failed to read tests: %w", err)
+	}
+
+	exercise.Templates, err = readFiles(filepath.Join(dir, "templates"))
+	if err != nil {
+		return exercise, fmt.Errorf("failed to read templates: %w", err)
+	}
+
+	exercise.Solution, err = readFiles(filepath.Join(dir, "solution"))
+	if err != nil {
+		return exercise, fmt.Errorf("failed to read solution: %w", err)
+	}
+
+	exercise.Cases, err = readCases(filepath.Join(dir, "cases"))
+	if err != nil {
+		return exercise, fmt.Errorf("failed to read cases: %w", err)
+	}
+
+	if exercise.Checker != nil {
+		exercise.Checker.Source, err = readFiles(filepath.Join(dir, "checker"))
+		if err != nil {
+			return exercise, fmt.Errorf("failed to read checker: %w", err)
+		}
+	}
+
+	if exercise.Interactor != nil {
+		exercise.Interactor.Source, err = readFiles(filepath.Join(dir, "interactor"))
+		if err != nil {
+			return exercise, fmt.Errorf("failed to read interactor: %w", err)
+		}
+	}
+
+	return exercise, nil
+}
+
+// version is written to a temporary directory first and renamed,
+// readers never see a half-written version
+func (s *Store) writeVersion(exercise types.Exercise) error {
+	exerciseDir := filepath.Join(s.root, exercise.Id)
+	if err := os.MkdirAll(exerciseDir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp(exerciseDir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return err
+	}
+
+	content, err := yaml.Marshal(exercise)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(tmp, "exercise.yaml"), content, 0644); err != nil {
+		return err
+	}
+
+	if err := writeFiles(filepath.Join(tmp, "tests"), exercise.Tests); err != nil {
+		return fmt.Errorf("failed to write tests: %w", err)
+	}
+
+	if err := writeFiles(filepath.Join(tmp, "templates"), exercise.Templates); err != nil {
+		return fmt.Errorf("failed to write templates: %w", err)
+	}
+
+	if err := writeFiles(filepath.Join(tmp, "solution"), exercise.Solution); err != nil {
+		return fmt.Errorf("failed to write solution: %w", err)
+	}
+
+	if err := writeCases(filepath.Join(tmp, "cases"), exercise.Cases); err != nil {
+		return fmt.Errorf("failed to write cases: %w", err)
+	}
+
+	if exercise.Checker != nil {
+		if err := writeFiles(filepath.Join(tmp, "checker"), exercise.Checker.Source); err != nil {
+			return fmt.Errorf("failed to write checker: %w", err)
+		}
+	}
+
+	if exercise.Interactor != nil {
+		if err := writeFiles(filepath.Join(tmp, "interactor"), exercise.Interactor.Source); err != nil {
+			return fmt.Errorf("failed to write interactor: %w", err)
+		}
+	}
+
+	return os.Rename(tmp, s.versionDir(exercise.Id, exercise.Version))
+}
+
+func (s *Store) versionDir(id string, version int) string {
+	return filepath.Join(s.root, id, strconv.Itoa(version))
+}
+
+func (s *Store) deletedMark(id string) string {
+	return filepath.Join(s.root, id, "deleted")
+}
+
+func (s *Store) isDeleted(id string) bool {
+	return !util.IsNotExist(s.deletedMark(id))
+}
+
+// read regular files directly under dir, missing dir is treated as empty
+func readFiles(dir string) ([]types.SourceFile, error) {
+	files := []types.SourceFile{}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return files, nil
+	} else if err != nil {
+		return files, err
+	}
+
+	for _, v := range entries {
+		if !v.Type().IsRegular() {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, v.Name()))
+		if err != nil {
+			return files, err
+		}
+
+		files = append(files, types.SourceFile{Filename: v.Name(), SourceCode: string(content)})
+	}
+
+	return files, nil
+}
+
+func writeFiles(dir string, files []types.SourceFile) error {
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, v := range files {
+		if !util.IsValidFilename(v.Filename) {
+			return fmt.Errorf("invalid filename %q", v.Filename)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, v.Filename), []byte(v.SourceCode), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cases are numbered from 1 in order, missing dir is treated as empty
+func readCases(dir string) ([]types.TestCase, error) {
+	cases := []types.TestCase{}
+	for n := 1; ; n++ {
+		input, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(n)+".in"))
+		if errors.Is(err, os.ErrNotExist) {
+			return cases, nil
+		} else if err != nil {
+			return cases, err
+		}
+
+		output, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(n)+".out"))
+		if err != nil {
+			return cases, err
+		}
+
+		testCase := types.TestCase{Input: string(input), Output: string(output)}
+
+		// comparison is only written when it is not the default one
+		content, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(n)+".yaml"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return cases, err
+		} else if err == nil {
+			if err := yaml.Unmarshal(content, &testCase.Compare); err != nil {
+				return cases, err
+			}
+		}
+
+		cases = append(cases, testCase)
+	}
+}
+
+func writeCases(dir string, cases []types.TestCase) error {
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+
+	for i, v := range cases {
+		name := filepath.Join(dir, strconv.Itoa(i+1))
+		if err := os.WriteFile(name+".in", []byte(v.Input), 0644); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(name+".out", []byte(v.Output), 0644); err != nil {
+			return err
+		}
+
+		if v.Compare == (types.Comparison{}) {
+			continue
+		}
+
+		content, err := yaml.Marshal(v.Compare)
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(name+".yaml", content, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package exercise
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+func testExercise(test string) types.Exercise {
+	return types.Exercise{
+		Id:       "sum",
+		Type:     "python",
+		Tests:    []types.SourceFile{{Filename: "test_sum.py", SourceCode: test}},
+		Solution: []types.SourceFile{{Filename: "sum.py", SourceCode: "def sum(a, b): return a + b"}},
+		Cases: []types.TestCase{
+			{Input: "1 2", Output: "3"},
+			{Input: "0.1 0.2", Output: "0.3", Compare: types.Comparison{Mode: types.CompareNumeric, AbsEpsilon: 1e-9}},
+		},
+	}
+}
+
+func TestVersions(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	created, err := store.Create(testExercise("v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.Version != 1 {
+		t.Errorf("created version %d, want 1", created.Version)
+	}
+
+	for want := 2; want <= 11; want++ {
+		updated, err := store.Update(testExercise("v" + strconv.Itoa(want)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if updated.Version != want {
+			t.Fatalf("updated version %d, want %d", updated.Version, want)
+		}
+	}
+
+	// numeric order, 10 and 11 do not come before 2
+	versions, err := store.Versions("sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(versions) != 11 || !slices.IsSorted(versions) || versions[10] != 11 {
+		t.Errorf("versions %v", versions)
+	}
+
+	latest, err := store.Load("sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if latest.Version != 11 || latest.Tests[0].SourceCode != "v11" {
+		t.Errorf("latest is version %d with test %q", latest.Version, latest.Tests[0].SourceCode)
+	}
+}
+
+func TestLoadVersion(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	if _, err := store.Create(testExercise("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Update(testExercise("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	pinned, err := store.LoadVersion("sum", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pinned.Version != 1 || pinned.Tests[0].SourceCode != "first" {
+		t.Errorf("pinned is version %d with test %q", pinned.Version, pinned.Tests[0].SourceCode)
+	}
+
+	if len(pinned.Cases) != 2 || pinned.Cases[1].Compare.Mode != types.CompareNumeric || pinned.Cases[0].Compare.Mode != "" {
+		t.Errorf("cases %+v", pinned.Cases)
+	}
+
+	if _, err := store.LoadVersion("sum", 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("loading missing version: unexpected error %v", err)
+	}
+
+	if _, err := store.LoadVersion("../sum", 1); !errors.Is(err, ErrInvalidId) {
+		t.Errorf("loading invalid id: unexpected error %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	if _, err := store.Create(testExercise("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Create(testExercise("again")); !errors.Is(err, ErrExists) {
+		t.Errorf("creating existing exercise: unexpected error %v", err)
+	}
+
+	if err := store.Delete("sum"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Load("sum"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("loading deleted exercise: unexpected error %v", err)
+	}
+
+	if _, err := store.Update(testExercise("second")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("updating deleted exercise: unexpected error %v", err)
+	}
+
+	if err := store.Delete("sum"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting twice: unexpected error %v", err)
+	}
+
+	// past submissions still see their version, new ones do not
+	if _, err := store.LoadVersion("sum", 1); err != nil {
+		t.Errorf("loading version of deleted exercise: %v", err)
+	}
+
+	if _, err := store.LoadLiveVersion("sum", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("loading live version of deleted exercise: unexpected error %v", err)
+	}
+
+	recreated, err := store.Create(testExercise("recreated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recreated.Version != 2 {
+		t.Errorf("recreated version %d, want 2", recreated.Version)
+	}
+
+	latest, err := store.Load("sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if latest.Version != 2 || latest.Tests[0].SourceCode != "recreated" {
+		t.Errorf("latest is version %d with test %q", latest.Version, latest.Tests[0].SourceCode)
+	}
+
+	if _, err := store.LoadLiveVersion("sum", 1); err != nil {
+		t.Errorf("loading live version of recreated exercise: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package judge
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+// longer values are cut in mismatch explanation
+const snippetWidth = 80
+
+// numeric comparison without any epsilon set
+const defaultEpsilon = 1e-6
+
+// Comparator tells whether actual output matches the expected one,
+// explanation of the first mismatch is returned otherwise
+type Comparator func(c types.Comparison, expected, actual string) (bool, string)
+
+var comparators = map[types.CompareMode]Comparator{
+	types.CompareExact:              compareExact,
+	types.CompareTrailingWhitespace: compareTrailingWhitespace,
+	types.CompareTokens:             compareTokens,
+	types.CompareNumeric:            compareNumeric,
+	types.CompareUnorderedLines:     compareUnorderedLines,
+	types.CompareRegex:              compareRegex,
+}
+
+// Compare picks comparator of the test case, trailing_whitespace by default
+func Compare(c types.Comparison, expected, actual string) (bool, string) {
+	compare, ok := comparators[mode(c)]
+	if !ok {
+		return false, fmt.Sprintf("unknown comparison mode %q", c.Mode)
+	}
+
+	return compare(c, expected, actual)
+}
+
+// Validate checks the comparison is usable before an exercise gets stored
+func Validate(c types.Comparison, expected string) error {
+	if _, ok := comparators[mode(c)]; !ok {
+		return fmt.Errorf("unknown comparison mode %q", c.Mode)
+	}
+
+	if c.AbsEpsilon < 0 || c.RelEpsilon < 0 {
+		return fmt.Errorf("epsilon must not be negative")
+	}
+
+	if mode(c) == types.CompareRegex {
+		if _, err := regexp.Compile(expected); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func mode(c types.Comparison) types.CompareMode {
+	if c.Mode == "" {
+		return types.CompareTrailingWhitespace
+	}
+	return c.Mode
+}
+
+func compareExact(_ types.Comparison, expected, actual string) (bool, string) {
+	return compareLines(strings.Split(expected, "\n"), strings.Split(actual, "\n"))
+}
+
+func compareTrailingWhitespace(_ types.Comparison, expected, actual string) (bool, string) {
+	return compareLines(lines(expected), lines(actual))
+}
+
+func compareLines(want, got []string) (bool, string) {
+	for i := 0; i < max(len(want), len(got)); i++ {
+		switch {
+		case i >= len(got):
+			return false, fmt.Sprintf("line %d: expected %q, got end of output", i+1, cut(want[i]))
+		case i >= len(want):
+			return false, fmt.Sprintf("line %d: expected end of output, got %q", i+1, cut(got[i]))
+		case want[i] != got[i]:
+			return false, fmt.Sprintf("line %d: expected %q, got %q", i+1, cut(want[i]), cut(got[i]))
+		}
+	}
+
+	return true, ""
+}
+
+func compareTokens(_ types.Comparison, expected, actual string) (bool, string) {
+	return compareFields(strings.Fields(expected), strings.Fields(actual), func(w, g string) bool { return w == g })
+}
+
+// tokens that are not numbers in expected output are compared as is
+func compareNumeric(c types.Comparison, expected, actual string) (bool, string) {
+	abs, rel := c.AbsEpsilon, c.RelEpsilon
+	if abs == 0 && rel == 0 {
+		abs = defaultEpsilon
+	}
+
+	equal := func(w, g string) bool {
+		want, err := strconv.ParseFloat(w, 64)
+		if err != nil {
+			return w == g
+		}
+
+		got, err := strconv.ParseFloat(g, 64)
+		if err != nil || math.IsNaN(got) {
+			return false
+		}
+
+		// infinities are never within epsilon, their difference is NaN
+		if want == got {
+			return true
+		}
+
+		diff := math.Abs(want - got)
+		return diff <= abs || diff <= rel*math.Abs(want)
+	}
+
+	return compareFields(strings.Fields(expected), strings.Fields(actual), equal)
+}
+
+func compareFields(want, got []string, equal func(w, g string) bool) (bool, string) {
+	for i := 0; i < max(len(want), len(got)); i++ {
+		switch {
+		case i >= len(got):
+			return false, fmt.Sprintf("token %d: expected %q, got end of output", i+1, cut(want[i]))
+		case i >= len(want):
+			return false, fmt.Sprintf("token %d: expected end of output, got %q", i+1, cut(got[i]))
+		case !equal(want[i], got[i]):
+			return false, fmt.Sprintf("token %d: expected %q, got %q", i+1, cut(want[i]), cut(got[i]))
+		}
+	}
+
+	return true, ""
+}
+
+// lines are compared as multisets, trailing whitespaces are ignored
+func compareUnorderedLines(_ types.Comparison, expected, actual string) (bool, string) {
+	missing := map[string]int{}
+	for _, v := range lines(expected) {
+		missing[v]++
+	}
+
+	got := lines(actual)
+	for _, v := range got {
+		if missing[v] == 0 {
+			return false, fmt.Sprintf("unexpected line %q", cut(v))
+		}
+		missing[v]--
+	}
+
+	for _, v := range lines(expected) {
+		if missing[v] > 0 {
+			return false, fmt.Sprintf("missing line %q", cut(v))
+		}
+	}
+
+	return true, ""
+}
+
+// pattern has to match the whole output without its trailing whitespaces
+func compareRegex(_ types.Comparison, expected, actual string) (bool, string) {
+	pattern, err := regexp.Compile(`^(?:` + expected + `)$`)
+	if err != nil {
+		return false, fmt.Sprintf("invalid pattern: %v", err)
+	}
+
+	output := strings.Join(lines(actual), "\n")
+	if !pattern.MatchString(output) {
+		return false, fmt.Sprintf("output %q does not match %q", cut(output), cut(expected))
+	}
+
+	return true, ""
+}
+
+func lines(s string) []string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+func cut(s string) string {
+	if len(s) <= snippetWidth {
+		return s
+	}
+	return s[:snippetWidth] + "..."
+}
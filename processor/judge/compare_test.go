@@ -0,0 +1,105 @@
+package judge
+
+import (
+	"strings"
+	"testing"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+func TestCompare(t *testing.T) {
+	exact := types.Comparison{Mode: types.CompareExact}
+	tokens := types.Comparison{Mode: types.CompareTokens}
+	numeric := types.Comparison{Mode: types.CompareNumeric}
+	unordered := types.Comparison{Mode: types.CompareUnorderedLines}
+	regex := types.Comparison{Mode: types.CompareRegex}
+
+	tests := []struct {
+		name     string
+		c        types.Comparison
+		expected string
+		actual   string
+		match    bool
+		reason   string
+	}{
+		{"exact", exact, "1 2\n", "1 2\n", true, ""},
+		{"exact trailing space", exact, "1 2\n", "1 2 \n", false, `line 1: expected "1 2", got "1 2 "`},
+		{"exact missing newline", exact, "1 2\n", "1 2", false, `line 2: expected "", got end of output`},
+		{"exact case", exact, "Yes", "yes", false, `line 1: expected "Yes", got "yes"`},
+
+		{"trailing whitespace by default", types.Comparison{}, "a\nb", "a  \r\nb\t\n\n", true, ""},
+		{"trailing whitespace leading space", types.Comparison{}, "a", " a", false, `line 1: expected "a", got " a"`},
+		{"trailing whitespace extra line", types.Comparison{}, "a", "a\nb", false, `line 2: expected end of output, got "b"`},
+		{"trailing whitespace missing line", types.Comparison{}, "a\nb", "a\n", false, `line 2: expected "b", got end of output`},
+		{"trailing whitespace case", types.Comparison{}, "YES", "yes", false, `line 1: expected "YES", got "yes"`},
+
+		{"tokens", tokens, "1 2\n3", "  1\n2   3 ", true, ""},
+		{"tokens case", tokens, "Yes", "yes", false, `token 1: expected "Yes", got "yes"`},
+		{"tokens missing", tokens, "1 2 3", "1 2", false, `token 3: expected "3", got end of output`},
+		{"tokens extra", tokens, "1 2", "1 2 3", false, `token 3: expected end of output, got "3"`},
+
+		{"numeric default epsilon", numeric, "0.3", "0.3000001", true, ""},
+		{"numeric over default epsilon", numeric, "0.3", "0.30001", false, `token 1: expected "0.3", got "0.30001"`},
+		{"numeric absolute", types.Comparison{Mode: types.CompareNumeric, AbsEpsilon: 0.01}, "1.5", "1.509", true, ""},
+		{"numeric relative", types.Comparison{Mode: types.CompareNumeric, RelEpsilon: 1e-3}, "1000000", "1000999", true, ""},
+		{"numeric over relative", types.Comparison{Mode: types.CompareNumeric, RelEpsilon: 1e-3}, "1000000", "1001001", false, `token 1: expected "1000000", got "1001001"`},
+		{"numeric notation", numeric, "1000 -0", "1e3 0.0", true, ""},
+		{"numeric words", numeric, "answer 42", "answer 42.0", true, ""},
+		{"numeric word mismatch", numeric, "answer 42", "Answer 42", false, `token 1: expected "answer", got "Answer"`},
+		{"numeric not a number", numeric, "42", "forty-two", false, `token 1: expected "42", got "forty-two"`},
+		{"numeric infinity", numeric, "inf -inf", "+Inf -Inf", true, ""},
+		{"numeric infinity mismatch", numeric, "inf", "-inf", false, `token 1: expected "inf", got "-inf"`},
+		{"numeric nan", numeric, "1", "NaN", false, `token 1: expected "1", got "NaN"`},
+
+		{"unordered lines", unordered, "a\nb\nb", "b\na \nb\n", true, ""},
+		{"unordered lines unexpected", unordered, "a\nb", "a\nc", false, `unexpected line "c"`},
+		{"unordered lines missing", unordered, "a\nb\nb", "b\na", false, `missing line "b"`},
+
+		{"regex", regex, `\d+ (apples|pears)`, "12 pears  \n", true, ""},
+		{"regex whole output", regex, `\d+`, "12 pears", false, `output "12 pears" does not match "\\d+"`},
+
+		{"unknown mode", types.Comparison{Mode: "fuzzy"}, "a", "a", false, `unknown comparison mode "fuzzy"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, reason := Compare(tt.c, tt.expected, tt.actual)
+			if match != tt.match || reason != tt.reason {
+				t.Errorf("got %v %q, want %v %q", match, reason, tt.match, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCompareLongMismatch(t *testing.T) {
+	expected := strings.Repeat("a", snippetWidth+20)
+
+	_, reason := Compare(types.Comparison{}, expected, "b")
+	if want := `line 1: expected "` + strings.Repeat("a", snippetWidth) + `...", got "b"`; reason != want {
+		t.Errorf("reason %q, want %q", reason, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        types.Comparison
+		expected string
+		valid    bool
+	}{
+		{"default", types.Comparison{}, "", true},
+		{"numeric", types.Comparison{Mode: types.CompareNumeric, AbsEpsilon: 1e-9}, "0.3", true},
+		{"negative epsilon", types.Comparison{Mode: types.CompareNumeric, RelEpsilon: -1}, "0.3", false},
+		{"unknown mode", types.Comparison{Mode: "fuzzy"}, "", false},
+		{"regex", types.Comparison{Mode: types.CompareRegex}, `\d+`, true},
+		{"invalid regex", types.Comparison{Mode: types.CompareRegex}, `(\d+`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.c, tt.expected); (err == nil) != tt.valid {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
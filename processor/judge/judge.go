@@ -0,0 +1,122 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"codeberg.org/iklabib/kerat/processor/container"
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+// checker exit codes, anything else means the checker itself failed
+const (
+	checkerAccepted    = 0
+	checkerWrongAnswer = 1
+	checkerPartial     = 7 // score between 0 and 1 is the first line of stdout
+)
+
+// Check judges output of a program that exited normally
+type Check func(testCase types.TestCase, output []byte) (types.TestResult, error)
+
+// Case gives the verdict of a program run against a test case,
+// errors other than timeout are not caused by the submission and returned as is
+func Case(name string, testCase types.TestCase, rt types.Runtime, err error, check Check) (types.TestResult, error) {
+	res := types.TestResult{Name: name, Duration: rt.Metrics.WallTime}
+
+	if errors.Is(err, container.ErrTimeout) {
+		res.Verdict = types.VerdictTimeLimitExceeded
+		res.Message = "time limit exceeded"
+		return res, nil
+	} else if err != nil {
+		return res, err
+	}
+
+	// checked first, the program is killed when it prints too much
+	if rt.Metrics.OutputTruncated {
+		res.Verdict = types.VerdictOutputLimitExceeded
+		res.Message = "output limit exceeded"
+		return res, nil
+	}
+
+	if rt.Metrics.CPUTimeExceeded {
+		res.Verdict = types.VerdictCPUTimeExceeded
+		res.Message = "cpu time limit exceeded"
+		return res, nil
+	}
+
+	if rt.Metrics.OOMKilled {
+		res.Verdict = types.VerdictMemoryLimitExceeded
+		res.Message = "memory limit exceeded"
+		return res, nil
+	}
+
+	if rt.Metrics.ExitCode != 0 {
+		res.Verdict = types.VerdictRuntimeError
+		res.Message = fmt.Sprintf("exited with code %d", rt.Metrics.ExitCode)
+		res.StackTrace = string(rt.Stderr)
+		return res, nil
+	}
+
+	res, err = check(testCase, rt.Stdout)
+	res.Name = name
+	res.Duration = rt.Metrics.WallTime
+	return res, err
+}
+
+// Comparison checks output with the comparator of the test case
+func Comparison(testCase types.TestCase, output []byte) (types.TestResult, error) {
+	var res types.TestResult
+	if ok, diff := Compare(testCase.Compare, testCase.Output, string(output)); !ok {
+		res.Verdict = types.VerdictWrongAnswer
+		res.Message = diff
+		return res, nil
+	}
+
+	res.Passed = true
+	res.Verdict = types.VerdictAccepted
+	return res, nil
+}
+
+// CheckerResult reads the verdict of a checker run, its stderr becomes the message
+func CheckerResult(rt types.Runtime) (types.TestResult, error) {
+	res := types.TestResult{Message: strings.TrimSpace(string(rt.Stderr))}
+
+	var score float64
+	switch rt.Metrics.ExitCode {
+	case checkerAccepted:
+		res.Passed = true
+		res.Verdict = types.VerdictAccepted
+		score = 1
+	case checkerWrongAnswer:
+		res.Verdict = types.VerdictWrongAnswer
+	case checkerPartial:
+		line, _, _ := strings.Cut(string(rt.Stdout), "\n")
+		var err error
+		score, err = strconv.ParseFloat(strings.TrimSpace(line), 64)
+		if err != nil || score < 0 || score > 1 {
+			return res, fmt.Errorf("checker reported invalid score %q", line)
+		}
+		res.Verdict = types.VerdictPartial
+	default:
+		return res, fmt.Errorf("checker exited with code %d: %s", rt.Metrics.ExitCode, res.Message)
+	}
+
+	res.Score = &score
+	return res, nil
+}
+
+// InteractorResult reads the verdict of an interactor run like CheckerResult, its stdout
+// belongs to the program so a partial score is taken from the last line of stderr instead
+func InteractorResult(rt types.Runtime) (types.TestResult, error) {
+	rt.Stdout = nil
+	if rt.Metrics.ExitCode == checkerPartial {
+		stderr := strings.TrimRight(string(rt.Stderr), "\r\n")
+		i := strings.LastIndexByte(stderr, '\n')
+		rt.Stdout = []byte(stderr[i+1:])
+		rt.Stderr = []byte(stderr[:max(i, 0)])
+	}
+
+	return CheckerResult(rt)
+}
@@ -1,6 +1,7 @@
 package memo
 
 import (
+	"container/list"
 	"log"
 	"sync"
 	"time"
@@ -8,60 +9,303 @@ import (
 	"codeberg.org/iklabib/kerat/processor/toolchains"
 )
 
+// BoxCaches keeps toolchains of exercises across submissions.
+// Entry is cleaned after interval minutes without use, least recently used
+// entries are evicted once maxEntries or maxBytes of disk usage is exceeded.
+// Zero bound means unbounded. Toolchains taken by LoadToolchain or AddToolchain
+// are in use until Release, they are never cleaned meanwhile
 type BoxCaches struct {
-	interval  int
-	mu        sync.Mutex
-	timers    map[string]*time.Timer
-	exercises map[string]toolchains.Toolchain
+	interval   int
+	maxEntries int
+	maxBytes   int64
+	mu         sync.Mutex
+	lru        *list.List // front is the most recently used
+	exercises  map[string]*list.Element
+	purged     map[toolchains.Toolchain]*cacheEntry // removed while in use, cleaned once released
+	hits       uint64
+	misses     uint64
+	evictions  uint64
 }
 
-func NewBoxCaches(interval int) BoxCaches {
-	return BoxCaches{
-		interval:  interval,
-		mu:        sync.Mutex{},
-		timers:    make(map[string]*time.Timer),
-		exercises: make(map[string]toolchains.Toolchain),
+type cacheEntry struct {
+	id        string
+	tc        toolchains.Toolchain
+	timer     *time.Timer
+	size      int64
+	createdAt time.Time
+	lastUsed  time.Time
+	refs      int  // builds using the toolchain
+	purged    bool // no longer cached, cleaned once released
+}
+
+type CacheEntry struct {
+	Id        string    `json:"id"`
+	Size      int64     `json:"size"` // bytes
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+	InUse     int       `json:"in_use"` // builds using the toolchain
+	Purged    bool      `json:"purged"` // no longer cached, cleaned once no longer in use
+}
+
+type CacheStats struct {
+	Entries    int          `json:"entries"`
+	Size       int64        `json:"size"` // bytes
+	MaxEntries int          `json:"max_entries"`
+	MaxSize    int64        `json:"max_size"` // bytes
+	Hits       uint64       `json:"hits"`
+	Misses     uint64       `json:"misses"`
+	Evictions  uint64       `json:"evictions"`
+	Items      []CacheEntry `json:"items"`
+}
+
+func NewBoxCaches(interval int, maxEntries int, maxBytes int64) *BoxCaches {
+	return &BoxCaches{
+		interval:   interval,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		mu:         sync.Mutex{},
+		lru:        list.New(),
+		exercises:  make(map[string]*list.Element),
+		purged:     make(map[toolchains.Toolchain]*cacheEntry),
 	}
 }
 
 func (b *BoxCaches) LoadToolchain(id string) (toolchains.Toolchain, bool) {
 	b.mu.Lock()
-	tc, ok := b.exercises[id]
-	if existingTimer, exists := b.timers[id]; exists {
-		existingTimer.Stop()
-		delete(b.timers, id)
+	defer b.mu.Unlock()
+
+	elem, ok := b.exercises[id]
+	if !ok {
+		b.misses++
+		return nil, false
+	}
+	b.hits++
+
+	entry := elem.Value.(*cacheEntry)
+	entry.lastUsed = time.Now()
+	entry.refs++
+	entry.timer.Reset(b.intervalTime())
+	b.lru.MoveToFront(elem)
+
+	return entry.tc, true
+}
+
+// AddToolchain caches a prepared toolchain, evicting others to stay within bounds.
+// When another toolchain got cached under id meanwhile, that one is kept and returned.
+// Either way the returned toolchain is in use until Release
+func (b *BoxCaches) AddToolchain(id string, tc toolchains.Toolchain) toolchains.Toolchain {
+	size, err := tc.Size()
+	if err != nil {
+		log.Printf("Failed to measure cache entry %s: %v", id, err)
 	}
+
+	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if ok {
-		b.timers[id] = b.CleanTimer(id)
+	// both share the same workdir, do not clean either
+	if elem, ok := b.exercises[id]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.refs++
+		return entry.tc
 	}
 
-	return tc, ok
+	now := time.Now()
+	entry := &cacheEntry{
+		id:        id,
+		tc:        tc,
+		size:      size,
+		createdAt: now,
+		lastUsed:  now,
+		refs:      1,
+	}
+	entry.timer = b.CleanTimer(id)
+	b.exercises[id] = b.lru.PushFront(entry)
+
+	b.evict()
+
+	return tc
 }
 
-func (b *BoxCaches) AddToolchain(id string, tc toolchains.Toolchain) {
+// Release hands back a toolchain taken by LoadToolchain or AddToolchain,
+// the last build using a purged toolchain cleans it
+func (b *BoxCaches) Release(id string, tc toolchains.Toolchain) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.exercises[id] = tc
-	b.timers[id] = b.CleanTimer(id)
+	if entry, ok := b.purged[tc]; ok {
+		entry.refs--
+		if entry.refs == 0 {
+			delete(b.purged, tc)
+			b.clean(entry)
+		}
+		return
+	}
+
+	elem, ok := b.exercises[id]
+	if !ok {
+		return
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if entry.tc != tc {
+		return
+	}
+
+	entry.refs--
+	if entry.refs > 0 {
+		return
+	}
+
+	// bounds may have been exceeded while it was in use
+	entry.lastUsed = time.Now()
+	entry.timer.Reset(b.intervalTime())
+	b.evict()
 }
 
 func (b *BoxCaches) CleanTimer(id string) *time.Timer {
-	intervalTime := time.Duration(b.interval) * time.Minute
-	return time.AfterFunc(intervalTime, func() {
+	return time.AfterFunc(b.intervalTime(), func() {
 		b.mu.Lock()
 		defer b.mu.Unlock()
 
-		if tc, ok := b.exercises[id]; !ok {
+		elem, ok := b.exercises[id]
+		if !ok {
+			return
+		}
+
+		// timer fired right before being reset by LoadToolchain, or is reset by Release
+		entry := elem.Value.(*cacheEntry)
+		if entry.refs > 0 || time.Since(entry.lastUsed) < b.intervalTime() {
 			return
-		} else {
-			tc.Clean()
 		}
 
-		delete(b.exercises, id)
-		delete(b.timers, id)
+		b.remove(elem)
 		log.Printf("Cleaned up entry: %s", id)
 	})
 }
+
+// Purge removes a single entry, an entry in use is cleaned once released
+func (b *BoxCaches) Purge(id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	elem, ok := b.exercises[id]
+	if !ok {
+		return false
+	}
+
+	b.remove(elem)
+	return true
+}
+
+// PurgeAll removes every entry, entries in use are cleaned once released
+func (b *BoxCaches) PurgeAll() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := len(b.exercises)
+	for elem := b.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		b.remove(elem)
+		elem = prev
+	}
+
+	return count
+}
+
+func (b *BoxCaches) Stats() CacheStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := CacheStats{
+		Entries:    len(b.exercises),
+		Size:       b.size(),
+		MaxEntries: b.maxEntries,
+		MaxSize:    b.maxBytes,
+		Hits:       b.hits,
+		Misses:     b.misses,
+		Evictions:  b.evictions,
+		Items:      []CacheEntry{},
+	}
+
+	for elem := b.lru.Front(); elem != nil; elem = elem.Next() {
+		stats.Items = append(stats.Items, elem.Value.(*cacheEntry).stats())
+	}
+
+	// listed until released, they are not counted as entries
+	for _, entry := range b.purged {
+		stats.Items = append(stats.Items, entry.stats())
+	}
+
+	return stats
+}
+
+func (e *cacheEntry) stats() CacheEntry {
+	return CacheEntry{
+		Id:        e.id,
+		Size:      e.size,
+		CreatedAt: e.createdAt,
+		LastUsed:  e.lastUsed,
+		InUse:     e.refs,
+		Purged:    e.purged,
+	}
+}
+
+// evict least recently used entries until within bounds, the newest entry
+// and entries in use are always kept
+func (b *BoxCaches) evict() {
+	elem := b.lru.Back()
+	for elem != nil && elem != b.lru.Front() {
+		overEntries := b.maxEntries > 0 && b.lru.Len() > b.maxEntries
+		overBytes := b.maxBytes > 0 && b.size() > b.maxBytes
+		if !overEntries && !overBytes {
+			return
+		}
+
+		prev := elem.Prev()
+		if entry := elem.Value.(*cacheEntry); entry.refs == 0 {
+			log.Printf("Evicted entry: %s", entry.id)
+			b.remove(elem)
+			b.evictions++
+		}
+		elem = prev
+	}
+}
+
+// caller must hold the lock, entry is no longer cached right away so
+// the next build of id gets a fresh toolchain, an entry in use is cleaned by
+// its last Release
+func (b *BoxCaches) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	entry.timer.Stop()
+	b.lru.Remove(elem)
+	delete(b.exercises, entry.id)
+
+	if entry.refs > 0 {
+		entry.purged = true
+		b.purged[entry.tc] = entry
+		return
+	}
+
+	b.clean(entry)
+}
+
+// caller must hold the lock
+func (b *BoxCaches) clean(entry *cacheEntry) {
+	if err := entry.tc.Clean(); err != nil {
+		log.Printf("Failed to clean entry %s: %v", entry.id, err)
+	}
+}
+
+// caller must hold the lock
+func (b *BoxCaches) size() int64 {
+	var total int64
+	for elem := b.lru.Front(); elem != nil; elem = elem.Next() {
+		total += elem.Value.(*cacheEntry).size
+	}
+
+	return total
+}
+
+func (b *BoxCaches) intervalTime() time.Duration {
+	return time.Duration(b.interval) * time.Minute
+}
@@ -0,0 +1,209 @@
+package memo
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"codeberg.org/iklabib/kerat/processor/toolchains"
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+// interval long enough for entries to never be cleaned by timer during a test
+const testInterval = 60
+
+type fakeToolchain struct {
+	size    int64
+	cleaned int
+}
+
+func (f *fakeToolchain) Prep() error {
+	return nil
+}
+
+func (f *fakeToolchain) Build(ctx context.Context, source types.SourceCode, config types.SubmissionConfig) (types.Build, error) {
+	return types.Build{Success: true}, nil
+}
+
+func (f *fakeToolchain) Size() (int64, error) {
+	return f.size, nil
+}
+
+func (f *fakeToolchain) Clean() error {
+	f.cleaned++
+	return nil
+}
+
+// use caches tc under id and releases it right away, as a finished build does
+func use(b *BoxCaches, id string, tc toolchains.Toolchain) {
+	b.Release(id, b.AddToolchain(id, tc))
+}
+
+func cached(b *BoxCaches) []string {
+	var ids []string
+	for _, v := range b.Stats().Items {
+		if !v.Purged {
+			ids = append(ids, v.Id)
+		}
+	}
+
+	return ids
+}
+
+func TestLRU(t *testing.T) {
+	b := NewBoxCaches(testInterval, 2, 0)
+
+	a, c, d := &fakeToolchain{}, &fakeToolchain{}, &fakeToolchain{}
+	use(b, "a", a)
+	use(b, "c", c)
+
+	// a becomes the most recently used, c is evicted in its place
+	tc, ok := b.LoadToolchain("a")
+	if !ok || tc != a {
+		t.Fatalf("loaded %v, want a", tc)
+	}
+	b.Release("a", tc)
+
+	use(b, "d", d)
+
+	if ids := cached(b); !slices.Equal(ids, []string{"d", "a"}) {
+		t.Errorf("cached %v", ids)
+	}
+
+	if a.cleaned != 0 || c.cleaned != 1 || d.cleaned != 0 {
+		t.Errorf("cleaned a %d, c %d, d %d times", a.cleaned, c.cleaned, d.cleaned)
+	}
+
+	if _, ok := b.LoadToolchain("c"); ok {
+		t.Error("evicted entry is loaded")
+	}
+
+	stats := b.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Errorf("hits %d, misses %d, evictions %d", stats.Hits, stats.Misses, stats.Evictions)
+	}
+}
+
+func TestEvictBytes(t *testing.T) {
+	b := NewBoxCaches(testInterval, 0, 100)
+
+	a, c := &fakeToolchain{size: 60}, &fakeToolchain{size: 60}
+	use(b, "a", a)
+	use(b, "c", c)
+
+	if ids := cached(b); !slices.Equal(ids, []string{"c"}) {
+		t.Errorf("cached %v", ids)
+	}
+
+	if a.cleaned != 1 {
+		t.Errorf("evicted entry cleaned %d times", a.cleaned)
+	}
+
+	// the newest entry is kept even when it exceeds the bound alone
+	large := &fakeToolchain{size: 200}
+	use(b, "large", large)
+
+	if ids := cached(b); !slices.Equal(ids, []string{"large"}) {
+		t.Errorf("cached %v", ids)
+	}
+}
+
+func TestInUseKept(t *testing.T) {
+	b := NewBoxCaches(testInterval, 1, 0)
+
+	a, c := &fakeToolchain{}, &fakeToolchain{}
+	tc := b.AddToolchain("a", a)
+	use(b, "c", c)
+
+	if ids := cached(b); !slices.Equal(ids, []string{"c", "a"}) {
+		t.Errorf("cached %v while a is in use", ids)
+	}
+
+	// another build of a takes the same toolchain
+	if tc, ok := b.LoadToolchain("a"); !ok || tc != a {
+		t.Fatalf("loaded %v, want a", tc)
+	}
+	b.Release("a", tc)
+
+	if a.cleaned != 0 {
+		t.Fatal("cleaned while in use")
+	}
+
+	// over bounds once released, a is the newest entry though
+	b.Release("a", tc)
+	if ids := cached(b); !slices.Equal(ids, []string{"a"}) {
+		t.Errorf("cached %v", ids)
+	}
+
+	if a.cleaned != 0 || c.cleaned != 1 {
+		t.Errorf("cleaned a %d, c %d times", a.cleaned, c.cleaned)
+	}
+}
+
+func TestAddCached(t *testing.T) {
+	b := NewBoxCaches(testInterval, 0, 0)
+
+	a, other := &fakeToolchain{}, &fakeToolchain{}
+	first := b.AddToolchain("a", a)
+
+	// prepped concurrently, the cached one wins
+	if second := b.AddToolchain("a", other); second != a {
+		t.Fatalf("added %v, want the cached toolchain", second)
+	}
+
+	b.Release("a", first)
+	b.Release("a", first)
+
+	if stats := b.Stats(); stats.Items[0].InUse != 0 {
+		t.Errorf("in use by %d builds", stats.Items[0].InUse)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	b := NewBoxCaches(testInterval, 0, 0)
+
+	old := &fakeToolchain{}
+	tc := b.AddToolchain("a", old)
+
+	if !b.Purge("a") {
+		t.Fatal("entry not found")
+	}
+
+	if old.cleaned != 0 {
+		t.Fatal("cleaned while in use")
+	}
+
+	// purged entry is a miss, the next build gets a fresh toolchain
+	if _, ok := b.LoadToolchain("a"); ok {
+		t.Error("purged entry is loaded")
+	}
+
+	fresh := &fakeToolchain{}
+	if added := b.AddToolchain("a", fresh); added != fresh {
+		t.Error("purged entry is added again")
+	}
+
+	stats := b.Stats()
+	if stats.Entries != 1 || len(stats.Items) != 2 {
+		t.Errorf("%d entries with %d items", stats.Entries, len(stats.Items))
+	}
+
+	b.Release("a", tc)
+	if old.cleaned != 1 || fresh.cleaned != 0 {
+		t.Errorf("cleaned old %d, fresh %d times", old.cleaned, fresh.cleaned)
+	}
+
+	if stats := b.Stats(); len(stats.Items) != 1 || stats.Items[0].Purged {
+		t.Errorf("items %+v", stats.Items)
+	}
+
+	// entry not in use is cleaned right away
+	b.Release("a", fresh)
+	if b.PurgeAll() != 1 || fresh.cleaned != 1 {
+		t.Errorf("fresh cleaned %d times", fresh.cleaned)
+	}
+
+	if b.Purge("a") {
+		t.Error("purged twice")
+	}
+}
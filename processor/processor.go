@@ -1,19 +1,36 @@
 package processor
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"codeberg.org/iklabib/kerat/processor/container"
+	"codeberg.org/iklabib/kerat/processor/exercise"
+	"codeberg.org/iklabib/kerat/processor/judge"
 	"codeberg.org/iklabib/kerat/processor/memo"
 	"codeberg.org/iklabib/kerat/processor/toolchains"
 	"codeberg.org/iklabib/kerat/processor/types"
+	"codeberg.org/iklabib/kerat/util"
 )
 
+// ErrInvalidSubmission means the submission is rejected before grading
+var ErrInvalidSubmission = errors.New("invalid submission")
+
+// EventFunc is notified as a submission goes through its phases
+type EventFunc func(event types.Event)
+
 type SubmissionProcessor struct {
-	engine *container.Engine
-	config *types.Config
+	engine    *container.Pool
+	config    *types.Config
+	exercises *exercise.Store
+	caches    *memo.BoxCaches
 }
 
 func NewSubmissionProcessor(config *types.Config) (*SubmissionProcessor, error) {
@@ -22,56 +39,319 @@ func NewSubmissionProcessor(config *types.Config) (*SubmissionProcessor, error)
 		return nil, err
 	}
 
+	return NewSubmissionProcessorWithEngine(config, engine)
+}
+
+// NewSubmissionProcessorWithEngine allows swapping the container engine, e.g. with containertest.Fake
+func NewSubmissionProcessorWithEngine(config *types.Config, engine container.Engine) (*SubmissionProcessor, error) {
+	for _, v := range config.SubmissionConfigs {
+		if err := validateSubmissionConfig(v); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := engine.Check(); err != nil {
 		return nil, err
 	}
 
 	return &SubmissionProcessor{
-		engine: engine,
-		config: config,
+		engine:    container.NewPool(engine, config.SubmissionConfigs),
+		config:    config,
+		exercises: exercise.NewStore(config.Exercises),
+		caches:    memo.NewBoxCaches(config.CleanInterval, config.CacheMaxEntries, config.CacheMaxSize*1024*1024),
 	}, nil
 }
 
-func (p *SubmissionProcessor) ProcessSubmission(ctx context.Context, submission types.Submission, submissionId string) (types.SubmissionResult, error) {
-	if !p.engine.IsSupported(submission.Type) {
-		return types.SubmissionResult{}, fmt.Errorf("submission type %q is unsupported", submission.Type)
+// Close releases containers and toolchain caches held by the processor
+func (p *SubmissionProcessor) Close() {
+	p.engine.Close()
+	p.caches.PurgeAll()
+}
+
+// ProcessSubmission grades student sources against the exercise hidden tests,
+// tests sent by the client are never used
+func (p *SubmissionProcessor) ProcessSubmission(ctx context.Context, submission types.Submission, submissionId string, notify EventFunc) (types.SubmissionResult, error) {
+	ex, err := p.loadExercise(submission)
+	if err != nil {
+		return types.SubmissionResult{}, fmt.Errorf("failed to load exercise %q: %w", submission.ExerciseId, err)
+	}
+
+	if submission.Type != "" && submission.Type != ex.Type {
+		return types.SubmissionResult{}, fmt.Errorf("%w: exercise %q expects %q submission", ErrInvalidSubmission, ex.Id, ex.Type)
+	}
+
+	return p.grade(ctx, ex, submission.Source.Src, notify)
+}
+
+func (p *SubmissionProcessor) grade(ctx context.Context, ex types.Exercise, src []types.SourceFile, notify EventFunc) (types.SubmissionResult, error) {
+	config, ok := p.engine.SubmissionConfig(ex.Type)
+	if !ok || !runsSubmissions(config) {
+		return types.SubmissionResult{}, fmt.Errorf("submission type %q is unsupported", ex.Type)
 	}
+	config = OverrideConfig(config, ex.Config)
 
-	switch submission.Type {
-	case "python":
-		return p.processInterpretedSubmission(ctx, submission)
-	case "csharp":
-		return p.processCompiledSubmission(ctx, submission)
+	reserved, err := p.reservedFilenames(ex, config)
+	if err != nil {
+		return types.SubmissionResult{}, err
+	}
+
+	if err := validateSources(src, reserved); err != nil {
+		return types.SubmissionResult{}, fmt.Errorf("%w: %v", ErrInvalidSubmission, err)
+	}
+
+	submission := types.Submission{
+		ExerciseId: ex.Id,
+		Version:    ex.Version,
+		Type:       ex.Type,
+		Source: types.SourceCode{
+			Src:     src,
+			SrcTest: ex.Tests,
+		},
+		Cases:      ex.Cases,
+		Checker:    ex.Checker,
+		Interactor: ex.Interactor,
+	}
+
+	if notify == nil {
+		notify = func(types.Event) {}
+	}
+
+	var result types.SubmissionResult
+	switch config.Kind {
+	case types.KindInterpreted:
+		result, err = p.processInterpretedSubmission(ctx, submission, config, notify)
+	case types.KindCompiled:
+		result, err = p.processCompiledSubmission(ctx, submission, config, notify)
 	default:
-		return types.SubmissionResult{}, fmt.Errorf("unknown submission type: %s", submission.Type)
+		return types.SubmissionResult{}, fmt.Errorf("unknown kind %q of submission type %s", config.Kind, submission.Type)
 	}
+
+	result.ExerciseVersion = ex.Version
+	return result, classify(&result, err)
 }
 
-func (p *SubmissionProcessor) processInterpretedSubmission(ctx context.Context, submission types.Submission) (types.SubmissionResult, error) {
-	result := types.SubmissionResult{}
+// classify sets the verdict of a graded submission, errors caused by
+// the submission itself are turned into verdicts
+func classify(result *types.SubmissionResult, err error) error {
+	switch {
+	case errors.Is(err, container.ErrTimeout):
+		result.Verdict = types.SubmissionTimeLimit
+		result.Message = "time limit exceeded"
+		result.Tests = []types.TestResult{}
+	case errors.Is(err, container.ErrHarness):
+		result.Verdict = types.SubmissionHarnessError
+		result.Message = err.Error()
+		result.Tests = []types.TestResult{}
+	case err != nil:
+		result.Verdict = types.SubmissionInternalError
+		return err
+	case result.Verdict != "":
+		// decided while grading, e.g. failed build
+	case result.Metrics.OutputTruncated:
+		result.Verdict = types.SubmissionOutputLimit
+		result.Message = "output limit exceeded"
+	case result.Metrics.CPUTimeExceeded:
+		result.Verdict = types.SubmissionCPUTimeLimit
+		result.Message = fmt.Sprintf("cpu time limit exceeded, used %.2fs", float64(result.Metrics.CpuTime)/1e9)
+	case result.Metrics.OOMKilled && !result.Success:
+		result.Verdict = types.SubmissionMemoryLimit
+		result.Message = fmt.Sprintf("memory limit exceeded, peak usage %d MiB", result.Metrics.Memory/1024/1024)
+	case len(result.Tests) == 0 && result.Metrics.ExitCode == 0:
+		// nothing to grade whatever success says, e.g. submission exited early
+		result.Verdict = types.SubmissionHarnessError
+		result.Message = "no test results were reported"
+	case len(result.Tests) == 0:
+		// harness died or gave up before running any test
+		result.Verdict = types.SubmissionRuntimeError
+	case result.Success:
+		result.Verdict = types.SubmissionOK
+	default:
+		result.Verdict = failedTestVerdict(result.Tests)
+	}
+
+	return nil
+}
+
+// stdio test cases carry their own verdict, the first failing one decides
+func failedTestVerdict(tests []types.TestResult) types.SubmissionVerdict {
+	for _, v := range tests {
+		if v.Passed {
+			continue
+		}
+
+		switch v.Verdict {
+		case types.VerdictTimeLimitExceeded:
+			return types.SubmissionTimeLimit
+		case types.VerdictMemoryLimitExceeded:
+			return types.SubmissionMemoryLimit
+		case types.VerdictOutputLimitExceeded:
+			return types.SubmissionOutputLimit
+		case types.VerdictCPUTimeExceeded:
+			return types.SubmissionCPUTimeLimit
+		case types.VerdictRuntimeError:
+			return types.SubmissionRuntimeError
+		}
+		return types.SubmissionTestFailure
+	}
+
+	return types.SubmissionTestFailure
+}
+
+// pinned versions of deleted exercises are not graded anymore
+func (p *SubmissionProcessor) loadExercise(submission types.Submission) (types.Exercise, error) {
+	if submission.Version != 0 {
+		return p.exercises.LoadLiveVersion(submission.ExerciseId, submission.Version)
+	}
+
+	return p.exercises.Load(submission.ExerciseId)
+}
+
+func (p *SubmissionProcessor) Exercises() *exercise.Store {
+	return p.exercises
+}
+
+func (p *SubmissionProcessor) Caches() *memo.BoxCaches {
+	return p.caches
+}
+
+// ValidateExercise checks the exercise is well formed before it gets stored
+func (p *SubmissionProcessor) ValidateExercise(ex types.Exercise) error {
+	config, ok := p.engine.SubmissionConfig(ex.Type)
+	if !ok || !runsSubmissions(config) {
+		return fmt.Errorf("submission type %q is unsupported", ex.Type)
+	}
+
+	// stdio programs are judged by their output, there is nothing to test them with
+	if config.ResultFormat == types.ResultStdio && len(ex.Cases) == 0 {
+		return fmt.Errorf("exercise has no test cases")
+	} else if config.ResultFormat != types.ResultStdio && len(ex.Tests) == 0 {
+		return fmt.Errorf("exercise has no tests")
+	}
+
+	if len(ex.Solution) == 0 {
+		return fmt.Errorf("exercise has no reference solution")
+	}
+
+	for i, v := range ex.Cases {
+		if err := judge.Validate(v.Compare, v.Output); err != nil {
+			return fmt.Errorf("case %d: %w", i+1, err)
+		}
+	}
+
+	if (ex.Checker != nil || ex.Interactor != nil) && config.ResultFormat != types.ResultStdio {
+		return fmt.Errorf("checker and interactor need a stdio submission type")
+	}
+
+	if ex.Checker != nil && ex.Interactor != nil {
+		return fmt.Errorf("exercise has both checker and interactor")
+	}
 
-	containerId, err := p.engine.Create(context.Background(), submission.Type)
+	if ex.Checker != nil {
+		if err := p.validateProgram("checker", *ex.Checker, types.ResultChecker, "input", "output", "expected"); err != nil {
+			return err
+		}
+	}
+
+	if ex.Interactor != nil {
+		if err := p.validateProgram("interactor", *ex.Interactor, types.ResultInteractor, "input", "expected"); err != nil {
+			return err
+		}
+	}
+
+	if err := p.engine.ValidateConfig(OverrideConfig(config, ex.Config)); err != nil {
+		return err
+	}
+
+	files := slices.Concat(ex.Tests, ex.Templates, ex.Solution)
+	for _, v := range files {
+		if !util.IsValidFilename(v.Filename) {
+			return fmt.Errorf("invalid filename %q", v.Filename)
+		}
+	}
+
+	// students start from templates, they would not be able to submit them
+	reserved, err := p.reservedFilenames(ex, config)
 	if err != nil {
-		return result, fmt.Errorf("container creation error: %v", err)
+		return err
 	}
 
-	defer func() {
-		go p.engine.Remove(containerId)
-	}()
+	if err := validateSources(slices.Concat(ex.Templates, ex.Solution), reserved); err != nil {
+		return err
+	}
 
-	content, err := TarSources(submission.Source)
+	return nil
+}
+
+// validateProgram checks an exercise checker or interactor, reserved files
+// are written next to its sources on every run
+func (p *SubmissionProcessor) validateProgram(role string, program types.Checker, format types.ResultFormat, reserved ...string) error {
+	config, ok := p.engine.SubmissionConfig(program.Type)
+	if !ok || config.ResultFormat != format {
+		return fmt.Errorf("%s type %q is unsupported", role, program.Type)
+	}
+
+	if len(program.Source) == 0 {
+		return fmt.Errorf("%s has no source", role)
+	}
+
+	for _, v := range program.Source {
+		if !util.IsValidFilename(v.Filename) {
+			return fmt.Errorf("invalid filename %q", v.Filename)
+		}
+
+		if slices.Contains(reserved, v.Filename) {
+			return fmt.Errorf("%s filename %q is reserved", role, v.Filename)
+		}
+	}
+
+	return nil
+}
+
+// ReferenceError means the reference solution does not pass its own exercise
+type ReferenceError struct {
+	Reason string
+	Result types.SubmissionResult
+}
+
+func (e *ReferenceError) Error() string {
+	return "reference solution rejected: " + e.Reason
+}
+
+// VerifySolution grades the exercise reference solution through the usual pipeline,
+// every test has to pass within the exercise limits
+func (p *SubmissionProcessor) VerifySolution(ctx context.Context, ex types.Exercise) error {
+	result, err := p.grade(ctx, ex, ex.Solution, nil)
 	if err != nil {
-		return result, fmt.Errorf("creating tar error: %v", err)
+		return err
 	}
 
-	copyPayload := types.CopyPayload{ContainerId: containerId, Dest: "/workspace", Content: &content}
-	if err := p.engine.Copy(context.Background(), copyPayload); err != nil {
-		return result, fmt.Errorf("copying tar error: %v", err)
+	if result.Verdict == types.SubmissionOK {
+		return nil
+	}
+
+	// verdict alone does not tell which test, e.g. test_failure
+	reason := string(result.Verdict)
+	if result.Message != "" {
+		reason += ": " + result.Message
+	} else if i := slices.IndexFunc(result.Tests, func(v types.TestResult) bool { return !v.Passed }); i >= 0 {
+		reason += fmt.Sprintf(": test %q failed", result.Tests[i].Name)
+	}
+
+	return &ReferenceError{Reason: reason, Result: result}
+}
+
+func (p *SubmissionProcessor) processInterpretedSubmission(ctx context.Context, submission types.Submission, config types.SubmissionConfig, notify EventFunc) (types.SubmissionResult, error) {
+	result := types.SubmissionResult{}
+
+	content, err := TarSources(submission.Source)
+	if err != nil {
+		return result, fmt.Errorf("creating tar error: %v", err)
 	}
 
-	ret, err := p.engine.Run(ctx, types.RunPayload{ContainerId: containerId, SubmissionType: submission.Type})
+	notify(types.Event{Type: types.EventStarted, Status: types.StatusRunning})
+	ret, err := p.execute(ctx, submission, config, content.Bytes(), notify)
 	if err != nil {
-		return result, fmt.Errorf("run error: %v", err)
+		return result, fmt.Errorf("run error: %w", err)
 	}
 
 	result.Success = ret.Success
@@ -82,70 +362,401 @@ func (p *SubmissionProcessor) processInterpretedSubmission(ctx context.Context,
 	return result, nil
 }
 
-func (p *SubmissionProcessor) processCompiledSubmission(ctx context.Context, submission types.Submission) (types.SubmissionResult, error) {
-	caches := memo.NewBoxCaches(p.config.CleanInterval)
-	exerciseId := submission.ExerciseId
+func (p *SubmissionProcessor) processCompiledSubmission(ctx context.Context, submission types.Submission, config types.SubmissionConfig, notify EventFunc) (types.SubmissionResult, error) {
+	key := toolchains.Key(submission.ExerciseId, config)
 
 	result := types.SubmissionResult{}
 
-	tc, ok := caches.LoadToolchain(exerciseId)
+	notify(types.Event{Type: types.EventPrep, Status: types.StatusBuilding})
+	tc, ok := p.caches.LoadToolchain(key)
 	if !ok {
 		var err error
-		tc, err = toolchains.NewToolchain(submission, p.config.Repository)
+		tc, err = toolchains.NewToolchain(submission, p.config.Repository, p.engine, config)
 		if err != nil {
 			return result, fmt.Errorf("failed to create toolchain: %v", err)
 		}
-		caches.AddToolchain(exerciseId, tc)
-	}
 
-	if err := tc.Prep(); err != nil {
-		return result, fmt.Errorf("prep error: %v", err)
+		if err := tc.Prep(); err != nil {
+			return result, fmt.Errorf("prep error: %v", err)
+		}
+		tc = p.caches.AddToolchain(key, tc)
 	}
 
-	build, err := tc.Build()
-	if err != nil {
-		return result, fmt.Errorf("build error: %v", err)
+	// workdir is not cleaned while in use
+	defer p.caches.Release(key, tc)
+
+	// compiler running out of time is on the submission, e.g. template explosion
+	build, err := tc.Build(ctx, submission.Source, config)
+	if errors.Is(err, container.ErrTimeout) {
+		build.Stderr = fmt.Appendf(nil, "build exceeded time limit of %d seconds\n", config.Build.Timeout)
+	} else if err != nil {
+		return result, fmt.Errorf("build error: %w", err)
 	}
 
+	// compilers disagree on where diagnostics go
+	diagnostics := string(slices.Concat(build.Stdout, build.Stderr))
+	notify(types.Event{
+		Type:    types.EventBuild,
+		Status:  types.StatusBuilding,
+		Success: &build.Success,
+		Build:   diagnostics,
+	})
+
 	if !build.Success {
-		result.Build = string(build.Stdout)
+		result.Verdict = types.SubmissionCompileError
+		result.Build = diagnostics
 		result.Tests = []types.TestResult{}
 
 		return result, nil
 	}
 
-	bin, err := os.ReadFile(build.BinPath)
+	notify(types.Event{Type: types.EventStarted, Status: types.StatusRunning})
+	ret, err := p.execute(ctx, submission, config, build.Artifact, notify)
+	if err != nil {
+		return result, fmt.Errorf("runtime error: %w", err)
+	}
+
+	result.Success = ret.Success
+	result.Tests = ret.Output
+	result.Metrics = ret.Metrics
+
+	return result, nil
+}
+
+// execute runs the submission in a new container with content (TAR) copied to its workdir,
+// stdio submission types get a new container for every test case
+func (p *SubmissionProcessor) execute(ctx context.Context, submission types.Submission, config types.SubmissionConfig, content []byte, notify EventFunc) (types.ContainerResult, error) {
+	if config.ResultFormat == types.ResultStdio {
+		return p.judgeCases(ctx, submission, config, content, notify)
+	}
+
+	containerId, err := p.prepare(config, content)
 	if err != nil {
-		return result, fmt.Errorf("failed to read binary: %v", err)
+		return types.ContainerResult{}, err
+	}
+	defer func() {
+		go p.engine.Remove(containerId)
+	}()
+
+	// Run returns only once the output is drained, streamed is not written afterwards
+	streamed := 0
+	onTest := func(test types.TestResult) {
+		streamed++
+		notify(types.Event{Type: types.EventTest, Status: types.StatusRunning, Test: &test})
 	}
 
-	containerId, err := p.engine.Create(context.Background(), submission.Type)
+	ret, err := p.run(ctx, containerId, config, onTest)
 	if err != nil {
-		return result, fmt.Errorf("container creation error: %v", err)
+		return ret, err
+	}
+
+	// tests only found in the aggregate, e.g. harness not streaming or unfinished tests
+	notifyTests(notify, ret.Output[min(streamed, len(ret.Output)):])
+
+	return ret, nil
+}
+
+// judgeCases runs the program once per test case and compares its output,
+// every case is judged on its own so a failing case does not stop the rest
+func (p *SubmissionProcessor) judgeCases(ctx context.Context, submission types.Submission, config types.SubmissionConfig, content []byte, notify EventFunc) (types.ContainerResult, error) {
+	res := types.ContainerResult{Success: true, Output: []types.TestResult{}}
+
+	check := judge.Comparison
+	if submission.Checker != nil {
+		var err error
+		check, err = p.checker(ctx, *submission.Checker)
+		if err != nil {
+			return res, err
+		}
 	}
 
+	for i, v := range submission.Cases {
+		name := fmt.Sprintf("case %d", i+1)
+
+		var test types.TestResult
+		var rt types.Runtime
+		var err error
+		if submission.Interactor != nil {
+			test, rt, err = p.interact(ctx, name, v, *submission.Interactor, config, content)
+		} else {
+			test, rt, err = p.runCase(ctx, name, v, config, content, check)
+		}
+		if err != nil {
+			return res, err
+		}
+		notify(types.Event{Type: types.EventTest, Status: types.StatusRunning, Test: &test})
+
+		res.Success = res.Success && test.Passed
+		res.Output = append(res.Output, test)
+		res.Metrics.WallTime = max(res.Metrics.WallTime, rt.Metrics.WallTime)
+		res.Metrics.CpuTime = max(res.Metrics.CpuTime, rt.Metrics.CpuTime)
+		res.Metrics.Memory = max(res.Metrics.Memory, rt.Metrics.Memory)
+	}
+
+	return res, nil
+}
+
+// runCase runs the program once with the test case input on its stdin
+func (p *SubmissionProcessor) runCase(ctx context.Context, name string, testCase types.TestCase, config types.SubmissionConfig, content []byte, check judge.Check) (types.TestResult, types.Runtime, error) {
+	containerId, err := p.prepare(config, content)
+	if err != nil {
+		return types.TestResult{}, types.Runtime{}, err
+	}
 	defer func() {
 		go p.engine.Remove(containerId)
 	}()
 
-	content, err := TarBinary("box", bin)
+	payload := types.RunPayload{ContainerId: containerId, Config: config, Stdin: strings.NewReader(testCase.Input)}
+	rt, err := p.engine.Execute(ctx, payload)
+
+	test, err := judge.Case(name, testCase, rt, err, check)
+	return test, rt, err
+}
+
+// interact runs the program and the exercise interactor side by side, stdout of
+// each is connected to stdin of the other. The interactor verdict goes first,
+// the program is judged by how it exited only when the interactor accepts
+func (p *SubmissionProcessor) interact(ctx context.Context, name string, testCase types.TestCase, interactor types.Checker, config types.SubmissionConfig, content []byte) (types.TestResult, types.Runtime, error) {
+	interactorConfig, ok := p.engine.SubmissionConfig(interactor.Type)
+	if !ok || interactorConfig.ResultFormat != types.ResultInteractor {
+		return types.TestResult{}, types.Runtime{}, fmt.Errorf("interactor type %q is unsupported", interactor.Type)
+	}
+
+	files := types.SourceCode{
+		Src: interactor.Source,
+		SrcTest: []types.SourceFile{
+			{Filename: "input", SourceCode: testCase.Input},
+			{Filename: "expected", SourceCode: testCase.Output},
+		},
+	}
+
+	interactorContent, err := TarSources(files)
 	if err != nil {
-		return result, fmt.Errorf("creating tar error: %v", err)
+		return types.TestResult{}, types.Runtime{}, fmt.Errorf("creating tar error: %v", err)
+	}
+
+	interactorId, err := p.prepare(interactorConfig, interactorContent.Bytes())
+	if err != nil {
+		return types.TestResult{}, types.Runtime{}, err
+	}
+	defer func() {
+		go p.engine.Remove(interactorId)
+	}()
+
+	programId, err := p.prepare(config, content)
+	if err != nil {
+		return types.TestResult{}, types.Runtime{}, err
+	}
+	defer func() {
+		go p.engine.Remove(programId)
+	}()
+
+	toInteractorR, toInteractorW := io.Pipe()
+	toProgramR, toProgramW := io.Pipe()
+
+	programCtx, cancelProgram := context.WithCancel(ctx)
+	defer cancelProgram()
+
+	type outcome struct {
+		rt  types.Runtime
+		err error
+	}
+	programDone := make(chan outcome, 1)
+	go func() {
+		payload := types.RunPayload{ContainerId: programId, Config: config, Stdin: toProgramR, Stdout: peerWriter{toInteractorW}}
+		rt, err := p.engine.Execute(programCtx, payload)
+
+		// either side stopping unblocks the other
+		toInteractorW.Close()
+		toProgramR.Close()
+		programDone <- outcome{rt, err}
+	}()
+
+	payload := types.RunPayload{ContainerId: interactorId, Config: interactorConfig, Stdin: toInteractorR, Stdout: peerWriter{toProgramW}}
+	rt, err := p.engine.Execute(ctx, payload)
+	toProgramW.Close()
+	toInteractorR.Close()
+
+	// interactor timing out is not on the submission, it must not pass as ErrTimeout
+	var verdict types.TestResult
+	if err == nil {
+		verdict, err = judge.InteractorResult(rt)
+	}
+	if err != nil {
+		err = fmt.Errorf("interactor error: %v", err)
+	}
+
+	// interactor has seen enough, program is stopped along with its container
+	if err == nil && !verdict.Passed {
+		cancelProgram()
+		<-programDone
+
+		verdict.Name = name
+		return verdict, types.Runtime{}, nil
+	}
+
+	// program timing out likely made the interactor time out as well
+	program := <-programDone
+	check := func(types.TestCase, []byte) (types.TestResult, error) {
+		return verdict, err
+	}
+
+	test, err := judge.Case(name, testCase, program.rt, program.err, check)
+	return test, program.rt, err
+}
+
+// peerWriter discards what is written once the other side of an interaction
+// stopped reading, e.g. a program still printing after the interactor is done
+type peerWriter struct {
+	w *io.PipeWriter
+}
+
+func (p peerWriter) Write(b []byte) (int, error) {
+	if _, err := p.w.Write(b); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// checker runs the exercise checker in its own container for every output,
+// with the test case input, the program output, and the expected output
+func (p *SubmissionProcessor) checker(ctx context.Context, checker types.Checker) (judge.Check, error) {
+	config, ok := p.engine.SubmissionConfig(checker.Type)
+	if !ok || config.ResultFormat != types.ResultChecker {
+		return nil, fmt.Errorf("checker type %q is unsupported", checker.Type)
 	}
 
-	copyPayload := types.CopyPayload{ContainerId: containerId, Dest: "/workspace", Content: &content}
+	check := func(testCase types.TestCase, output []byte) (types.TestResult, error) {
+		files := types.SourceCode{
+			Src: checker.Source,
+			SrcTest: []types.SourceFile{
+				{Filename: "input", SourceCode: testCase.Input},
+				{Filename: "output", SourceCode: string(output)},
+				{Filename: "expected", SourceCode: testCase.Output},
+			},
+		}
+
+		content, err := TarSources(files)
+		if err != nil {
+			return types.TestResult{}, fmt.Errorf("creating tar error: %v", err)
+		}
+
+		containerId, err := p.prepare(config, content.Bytes())
+		if err != nil {
+			return types.TestResult{}, err
+		}
+		defer func() {
+			go p.engine.Remove(containerId)
+		}()
+
+		// not wrapped, checker timing out is not on the submission
+		rt, err := p.engine.Execute(ctx, types.RunPayload{ContainerId: containerId, Config: config})
+		if err != nil {
+			return types.TestResult{}, fmt.Errorf("checker error: %v", err)
+		}
+
+		return judge.CheckerResult(rt)
+	}
+
+	return check, nil
+}
+
+// prepare creates a submission container with content copied to its workdir
+func (p *SubmissionProcessor) prepare(config types.SubmissionConfig, content []byte) (string, error) {
+	containerId, err := p.engine.Create(context.Background(), config)
+	if err != nil {
+		return "", fmt.Errorf("container creation error: %v", err)
+	}
+
+	copyPayload := types.CopyPayload{ContainerId: containerId, Dest: config.Workdir, Content: bytes.NewReader(content)}
 	if err := p.engine.Copy(context.Background(), copyPayload); err != nil {
-		return result, fmt.Errorf("copying tar error: %v", err)
+		go p.engine.Remove(containerId)
+		return "", fmt.Errorf("copying tar error: %v", err)
 	}
 
-	ret, err := p.engine.Run(ctx, types.RunPayload{ContainerId: containerId, SubmissionType: submission.Type})
+	return containerId, nil
+}
+
+// run starts the submission container and reads its results in the configured format
+func (p *SubmissionProcessor) run(ctx context.Context, containerId string, config types.SubmissionConfig, onTest func(types.TestResult)) (types.ContainerResult, error) {
+	payload := types.RunPayload{ContainerId: containerId, Config: config, OnTest: onTest}
+	if config.ResultFormat != types.ResultExitCode {
+		return p.engine.Run(ctx, payload)
+	}
+
+	var res types.ContainerResult
+	rt, err := p.engine.Execute(ctx, payload)
 	if err != nil {
-		return result, fmt.Errorf("runtime error: %v", err)
+		return res, err
 	}
 
-	result.Success = ret.Success
-	result.Tests = ret.Output
-	result.Metrics = ret.Metrics
+	test := types.TestResult{Passed: rt.Metrics.ExitCode == 0, Name: config.Id}
+	if rt.Metrics.OutputTruncated {
+		test.Passed = false
+		test.Message = "output limit exceeded"
+	} else if rt.Metrics.CPUTimeExceeded {
+		test.Passed = false
+		test.Message = "cpu time limit exceeded"
+	} else if rt.Metrics.OOMKilled {
+		test.Message = "memory limit exceeded"
+	} else if !test.Passed {
+		test.Message = string(rt.Stderr)
+	}
 
-	return result, nil
+	res.Success = test.Passed
+	res.Output = []types.TestResult{test}
+	res.Metrics = rt.Metrics
+	return res, nil
+}
+
+// checker and interactor types only run exercise programs
+func runsSubmissions(config types.SubmissionConfig) bool {
+	return config.ResultFormat != types.ResultChecker && config.ResultFormat != types.ResultInteractor
+}
+
+// notifyTests sends a test event for each of tests
+func notifyTests(notify EventFunc, tests []types.TestResult) {
+	for i := range tests {
+		notify(types.Event{Type: types.EventTest, Status: types.StatusRunning, Test: &tests[i]})
+	}
+}
+
+// reservedFilenames lists files next to the sources that submissions must not
+// replace: exercise tests, build template, and harness files of the submission type
+func (p *SubmissionProcessor) reservedFilenames(ex types.Exercise, config types.SubmissionConfig) ([]string, error) {
+	reserved := slices.Clone(config.Reserved)
+	for _, v := range ex.Tests {
+		reserved = append(reserved, v.Filename)
+	}
+
+	if config.Build != nil && config.Build.Template != "" {
+		entries, err := os.ReadDir(filepath.Join(p.config.Repository, config.Build.Template))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read build template: %w", err)
+		}
+
+		for _, v := range entries {
+			reserved = append(reserved, v.Name())
+		}
+	}
+
+	return reserved, nil
+}
+
+// student files are written as is to the workdir, make sure they
+// stay there and do not shadow the exercise or its harness
+func validateSources(src []types.SourceFile, reserved []string) error {
+	for _, v := range src {
+		name := v.Filename
+		if !util.IsValidFilename(name) {
+			return fmt.Errorf("invalid filename %q", name)
+		}
+
+		if slices.Contains(reserved, name) {
+			return fmt.Errorf("filename %q is reserved", name)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,398 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codeberg.org/iklabib/kerat/processor/container"
+	"codeberg.org/iklabib/kerat/processor/container/containertest"
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+func newTestProcessor(t *testing.T) (*SubmissionProcessor, *containertest.Fake) {
+	t.Helper()
+
+	repository := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repository, "csharp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Program.cs", "box.csproj"} {
+		if err := os.WriteFile(filepath.Join(repository, "csharp", name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	build := &types.BuildConfig{
+		SubmissionConfig: types.SubmissionConfig{ContainerImage: "dotnet-sdk"},
+		Template:         "csharp",
+		Artifact:         "/workspace/output/box",
+	}
+
+	config := &types.Config{
+		Repository: repository,
+		Exercises:  t.TempDir(),
+		SubmissionConfigs: []types.SubmissionConfig{
+			{Id: "csharp", ContainerImage: "dotnet", Build: build, Reserved: []string{"kerat.cs"}},
+			{Id: "python", ContainerImage: "python"},
+			{Id: "python-stdio", ContainerImage: "python", ResultFormat: types.ResultStdio},
+			{Id: "python-checker", ContainerImage: "python", ResultFormat: types.ResultChecker},
+			{Id: "python-interactor", ContainerImage: "python", ResultFormat: types.ResultInteractor},
+		},
+	}
+	for i := range config.SubmissionConfigs {
+		setDefaults(&config.SubmissionConfigs[i])
+	}
+
+	engine, err := containertest.NewFake(*config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewSubmissionProcessorWithEngine(config, engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	return p, engine
+}
+
+// submit creates the exercise and grades a submission of it
+func submit(t *testing.T, p *SubmissionProcessor, ex types.Exercise) (types.SubmissionResult, error) {
+	t.Helper()
+
+	ex.Id = "exercise"
+	if _, err := p.Exercises().Create(ex); err != nil {
+		t.Fatal(err)
+	}
+
+	src := []types.SourceFile{{Filename: "main.py", SourceCode: "print(input())"}}
+	submission := types.Submission{ExerciseId: ex.Id, Source: types.SourceCode{Src: src}}
+	return p.ProcessSubmission(context.Background(), submission, "test", nil)
+}
+
+func stdioExercise(cases ...types.TestCase) types.Exercise {
+	return types.Exercise{Type: "python-stdio", Cases: cases}
+}
+
+func stdout(exitCode int64, out string) containertest.FakeRun {
+	return containertest.FakeRun{Runtime: types.Runtime{Stdout: []byte(out), Metrics: types.Metrics{ExitCode: exitCode}}}
+}
+
+func stderr(exitCode int64, out string) containertest.FakeRun {
+	return containertest.FakeRun{Runtime: types.Runtime{Stderr: []byte(out), Metrics: types.Metrics{ExitCode: exitCode}}}
+}
+
+func TestClassify(t *testing.T) {
+	passed := []types.TestResult{{Passed: true, Name: "a"}}
+	failed := []types.TestResult{{Passed: true, Name: "a"}, {Name: "b"}}
+	timedOut := []types.TestResult{{Name: "a", Verdict: types.VerdictTimeLimitExceeded}}
+
+	tests := []struct {
+		name    string
+		result  types.SubmissionResult
+		err     error
+		verdict types.SubmissionVerdict
+		failed  bool
+	}{
+		{"timeout", types.SubmissionResult{Tests: passed}, container.ErrTimeout, types.SubmissionTimeLimit, false},
+		{"harness", types.SubmissionResult{}, container.ErrHarness, types.SubmissionHarnessError, false},
+		{"internal", types.SubmissionResult{}, errors.New("engine is gone"), types.SubmissionInternalError, true},
+		{"compile error", types.SubmissionResult{Verdict: types.SubmissionCompileError}, nil, types.SubmissionCompileError, false},
+		{"output limit", types.SubmissionResult{Metrics: types.Metrics{OutputTruncated: true}}, nil, types.SubmissionOutputLimit, false},
+		{"cpu time limit", types.SubmissionResult{Metrics: types.Metrics{CPUTimeExceeded: true}}, nil, types.SubmissionCPUTimeLimit, false},
+		{"memory limit", types.SubmissionResult{Metrics: types.Metrics{OOMKilled: true, ExitCode: 137}}, nil, types.SubmissionMemoryLimit, false},
+		{"successful without tests", types.SubmissionResult{Success: true}, nil, types.SubmissionHarnessError, false},
+		{"crashed without tests", types.SubmissionResult{Metrics: types.Metrics{ExitCode: 1}}, nil, types.SubmissionRuntimeError, false},
+		{"passed", types.SubmissionResult{Success: true, Tests: passed}, nil, types.SubmissionOK, false},
+		{"failed", types.SubmissionResult{Tests: failed}, nil, types.SubmissionTestFailure, false},
+		{"test timed out", types.SubmissionResult{Tests: timedOut}, nil, types.SubmissionTimeLimit, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.result
+			err := classify(&result, tt.err)
+			if (err != nil) != tt.failed {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if result.Verdict != tt.verdict {
+				t.Errorf("verdict %q, want %q", result.Verdict, tt.verdict)
+			}
+		})
+	}
+}
+
+func TestHarness(t *testing.T) {
+	p, engine := newTestProcessor(t)
+	engine.Script("python", containertest.FakeRun{Result: types.ContainerResult{
+		Success: true,
+		Output:  []types.TestResult{{Passed: true, Name: "test_add"}},
+	}})
+
+	ex := types.Exercise{Type: "python", Tests: []types.SourceFile{{Filename: "test_main.py"}}}
+	result, err := submit(t, p, ex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Verdict != types.SubmissionOK || len(result.Tests) != 1 || result.ExerciseVersion != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestHarnessEvents(t *testing.T) {
+	p, engine := newTestProcessor(t)
+	engine.Script("python", containertest.FakeRun{Result: types.ContainerResult{
+		Output: []types.TestResult{{Passed: true, Name: "test_add"}, {Name: "test_sub"}},
+	}})
+
+	ex := types.Exercise{Id: "exercise", Type: "python", Tests: []types.SourceFile{{Filename: "test_main.py"}}}
+	if _, err := p.Exercises().Create(ex); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests []string
+	notify := func(event types.Event) {
+		if event.Type == types.EventTest {
+			tests = append(tests, event.Test.Name)
+		}
+	}
+
+	src := []types.SourceFile{{Filename: "main.py"}}
+	submission := types.Submission{ExerciseId: ex.Id, Source: types.SourceCode{Src: src}}
+	if _, err := p.ProcessSubmission(context.Background(), submission, "test", notify); err != nil {
+		t.Fatal(err)
+	}
+
+	// streamed while running, not repeated from the aggregate
+	if len(tests) != 2 || tests[0] != "test_add" || tests[1] != "test_sub" {
+		t.Errorf("test events %v", tests)
+	}
+}
+
+func TestReservedFilenames(t *testing.T) {
+	p, _ := newTestProcessor(t)
+
+	ex := types.Exercise{Id: "exercise", Type: "csharp", Tests: []types.SourceFile{{Filename: "Tests.cs"}}}
+	if _, err := p.Exercises().Create(ex); err != nil {
+		t.Fatal(err)
+	}
+
+	// exercise tests, build template, and harness files of the type
+	for _, name := range []string{"Tests.cs", "Program.cs", "box.csproj", "kerat.cs"} {
+		src := []types.SourceFile{{Filename: name}}
+		submission := types.Submission{ExerciseId: ex.Id, Source: types.SourceCode{Src: src}}
+		_, err := p.ProcessSubmission(context.Background(), submission, "test", nil)
+		if !errors.Is(err, ErrInvalidSubmission) {
+			t.Errorf("submitting %s: unexpected error %v", name, err)
+		}
+	}
+}
+
+func TestHarnessTimeout(t *testing.T) {
+	p, engine := newTestProcessor(t)
+	engine.Script("python", containertest.FakeRun{Err: container.ErrTimeout})
+
+	ex := types.Exercise{Type: "python", Tests: []types.SourceFile{{Filename: "test_main.py"}}}
+	result, err := submit(t, p, ex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Verdict != types.SubmissionTimeLimit {
+		t.Errorf("verdict %q, want %q", result.Verdict, types.SubmissionTimeLimit)
+	}
+}
+
+func TestUnscriptedRun(t *testing.T) {
+	p, _ := newTestProcessor(t)
+
+	ex := types.Exercise{Type: "python", Tests: []types.SourceFile{{Filename: "test_main.py"}}}
+	result, err := submit(t, p, ex)
+	if err == nil || result.Verdict != types.SubmissionInternalError {
+		t.Errorf("unscripted run graded as %q", result.Verdict)
+	}
+}
+
+func TestStdio(t *testing.T) {
+	p, engine := newTestProcessor(t)
+	engine.Script("python-stdio",
+		stdout(0, "3\n"),
+		stdout(0, "4\n"),
+		containertest.FakeRun{Err: container.ErrTimeout},
+		stderr(1, "Traceback"),
+	)
+
+	result, err := submit(t, p, stdioExercise(
+		types.TestCase{Input: "1 2", Output: "3"},
+		types.TestCase{Input: "2 3", Output: "5"},
+		types.TestCase{Input: "3 4", Output: "7"},
+		types.TestCase{Input: "4 5", Output: "9"},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []types.Verdict{
+		types.VerdictAccepted,
+		types.VerdictWrongAnswer,
+		types.VerdictTimeLimitExceeded,
+		types.VerdictRuntimeError,
+	}
+	if len(result.Tests) != len(want) {
+		t.Fatalf("got %d tests, want %d", len(result.Tests), len(want))
+	}
+
+	for i, v := range want {
+		if result.Tests[i].Verdict != v {
+			t.Errorf("case %d: verdict %q, want %q", i+1, result.Tests[i].Verdict, v)
+		}
+	}
+
+	// first failing case decides
+	if result.Verdict != types.SubmissionTestFailure {
+		t.Errorf("verdict %q, want %q", result.Verdict, types.SubmissionTestFailure)
+	}
+}
+
+func TestChecker(t *testing.T) {
+	p, engine := newTestProcessor(t)
+	engine.Script("python-stdio", stdout(0, "1 2 3\n"), stdout(0, "3 1\n"))
+	engine.Script("python-checker", stdout(0, ""), stdout(7, "0.5\n"))
+
+	ex := stdioExercise(types.TestCase{Input: "3"}, types.TestCase{Input: "3"})
+	ex.Checker = &types.Checker{Type: "python-checker", Source: []types.SourceFile{{Filename: "checker.py"}}}
+	result, err := submit(t, p, ex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Tests) != 2 || !result.Tests[0].Passed || result.Tests[1].Verdict != types.VerdictPartial {
+		t.Fatalf("unexpected tests %+v", result.Tests)
+	}
+
+	if score := result.Tests[1].Score; score == nil || *score != 0.5 {
+		t.Errorf("unexpected score %v", score)
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	p, engine := newTestProcessor(t)
+	engine.Script("python-stdio", stdout(0, "1 2 3\n"))
+	engine.Script("python-checker", containertest.FakeRun{Err: container.ErrTimeout})
+
+	ex := stdioExercise(types.TestCase{Input: "3"})
+	ex.Checker = &types.Checker{Type: "python-checker", Source: []types.SourceFile{{Filename: "checker.py"}}}
+	result, err := submit(t, p, ex)
+
+	// the exercise checker is at fault, not the submission
+	if err == nil || result.Verdict != types.SubmissionInternalError {
+		t.Errorf("checker timeout graded as %q", result.Verdict)
+	}
+}
+
+func TestInteractor(t *testing.T) {
+	p, engine := newTestProcessor(t)
+	engine.Script("python-stdio", stdout(0, "guess 5\n"), stdout(0, "guess 7\n"), stdout(0, "guess 9\n"))
+	engine.Script("python-interactor",
+		stderr(0, ""),
+		stderr(7, "found it late\n0.25\n"),
+		stderr(1, "wrong guess"),
+	)
+
+	ex := stdioExercise(types.TestCase{Input: "5"}, types.TestCase{Input: "7"}, types.TestCase{Input: "8"})
+	ex.Interactor = &types.Checker{Type: "python-interactor", Source: []types.SourceFile{{Filename: "interactor.py"}}}
+	result, err := submit(t, p, ex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Tests) != 3 {
+		t.Fatalf("got %d tests, want 3", len(result.Tests))
+	}
+
+	if !result.Tests[0].Passed {
+		t.Errorf("case 1: unexpected result %+v", result.Tests[0])
+	}
+
+	partial := result.Tests[1]
+	if partial.Verdict != types.VerdictPartial || partial.Message != "found it late" || partial.Score == nil || *partial.Score != 0.25 {
+		t.Errorf("case 2: unexpected result %+v", partial)
+	}
+
+	if result.Tests[2].Verdict != types.VerdictWrongAnswer || result.Tests[2].Message != "wrong guess" {
+		t.Errorf("case 3: unexpected result %+v", result.Tests[2])
+	}
+}
+
+func TestInteractorTimeout(t *testing.T) {
+	p, engine := newTestProcessor(t)
+	engine.Script("python-stdio", stdout(0, "guess 5\n"))
+	engine.Script("python-interactor", containertest.FakeRun{Err: container.ErrTimeout})
+
+	ex := stdioExercise(types.TestCase{Input: "5"})
+	ex.Interactor = &types.Checker{Type: "python-interactor", Source: []types.SourceFile{{Filename: "interactor.py"}}}
+	result, err := submit(t, p, ex)
+
+	if err == nil || result.Verdict != types.SubmissionInternalError {
+		t.Errorf("interactor timeout graded as %q", result.Verdict)
+	}
+}
+
+func TestInteractorProgramTimeout(t *testing.T) {
+	p, engine := newTestProcessor(t)
+	engine.Script("python-stdio", containertest.FakeRun{Err: container.ErrTimeout})
+	engine.Script("python-interactor", stderr(0, ""))
+
+	ex := stdioExercise(types.TestCase{Input: "5"})
+	ex.Interactor = &types.Checker{Type: "python-interactor", Source: []types.SourceFile{{Filename: "interactor.py"}}}
+	result, err := submit(t, p, ex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Verdict != types.SubmissionTimeLimit || result.Tests[0].Verdict != types.VerdictTimeLimitExceeded {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestVerifySolution(t *testing.T) {
+	p, engine := newTestProcessor(t)
+
+	ex := stdioExercise(types.TestCase{Input: "1 2", Output: "3"}, types.TestCase{Input: "2 3", Output: "5"})
+	ex.Id = "exercise"
+	ex.Solution = []types.SourceFile{{Filename: "main.py"}}
+
+	engine.Script("python-stdio", stdout(0, "3\n"), stdout(0, "5\n"))
+	if err := p.VerifySolution(context.Background(), ex); err != nil {
+		t.Fatalf("passing solution rejected: %v", err)
+	}
+
+	// every limit verdict rejects the solution, not only failing tests
+	rejected := []containertest.FakeRun{
+		stdout(0, "4\n"),
+		{Runtime: types.Runtime{Stdout: []byte("3\n"), Metrics: types.Metrics{ExitCode: 137, OOMKilled: true}}},
+		{Runtime: types.Runtime{Stdout: []byte("3\n"), Metrics: types.Metrics{OutputTruncated: true}}},
+	}
+	want := []types.SubmissionVerdict{types.SubmissionTestFailure, types.SubmissionMemoryLimit, types.SubmissionOutputLimit}
+
+	for i, run := range rejected {
+		engine.Script("python-stdio", run, stdout(0, "5\n"))
+
+		err := p.VerifySolution(context.Background(), ex)
+
+		var refErr *ReferenceError
+		if !errors.As(err, &refErr) {
+			t.Fatalf("failing solution %d: unexpected error %v", i+1, err)
+		}
+
+		if refErr.Result.Verdict != want[i] {
+			t.Errorf("failing solution %d: verdict %q, want %q", i+1, refErr.Result.Verdict, want[i])
+		}
+	}
+}
@@ -1,23 +1,44 @@
 package toolchains
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
 
+	"codeberg.org/iklabib/kerat/processor/container"
 	"codeberg.org/iklabib/kerat/processor/types"
 )
 
+// Toolchain is created once per exercise, Prep readies what submissions share
+// and Build compiles a single submission in isolation, safe for concurrent use.
+// Build gets the submission config resolved for the exercise version being graded
 type Toolchain interface {
 	Prep() error
-	Build() (types.Build, error)
+	Build(ctx context.Context, source types.SourceCode, config types.SubmissionConfig) (types.Build, error)
+	Size() (int64, error) // disk usage in bytes
 	Clean() error
 }
 
-func NewToolchain(submission types.Submission, repository string) (Toolchain, error) {
-	switch submission.Type {
-	case "csharp":
-		cs, err := NewCsharp(submission, repository)
-		return cs, err
+// NewToolchain creates toolchain described by build config of the submission type
+func NewToolchain(submission types.Submission, repository string, engine container.Engine, config types.SubmissionConfig) (Toolchain, error) {
+	if config.Build == nil {
+		return nil, fmt.Errorf("no build config for \"%s\"", submission.Type)
 	}
 
-	return nil, fmt.Errorf("unsupported type \"%s\"", submission.Type)
+	template := ""
+	if config.Build.Template != "" {
+		template = filepath.Join(repository, config.Build.Template)
+	}
+
+	return NewWorkspace(Key(submission.ExerciseId, config), template, engine, *config.Build), nil
+}
+
+// Key identifies the toolchain of an exercise, it is only shared by submissions
+// of the same type built the same way, e.g. not across an exercise changing its type
+func Key(exerciseId string, config types.SubmissionConfig) string {
+	build, _ := json.Marshal(config.Build)
+	sum := sha256.Sum256(build)
+	return fmt.Sprintf("%s-%s-%x", exerciseId, config.Id, sum[:4])
 }
@@ -0,0 +1,207 @@
+package toolchains
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"codeberg.org/iklabib/kerat/processor/container"
+	"codeberg.org/iklabib/kerat/processor/types"
+	"codeberg.org/iklabib/kerat/util"
+)
+
+// Workspace builds submissions in a build container, it is shared by every
+// submission of an exercise and each build works on its own copy of the exercise workdir
+type Workspace struct {
+	id       string
+	template string
+	workdir  string
+	engine   container.Engine
+	config   types.BuildConfig
+	mu       *sync.RWMutex // shared by workspaces of the same workdir, see workdirLocks
+}
+
+// workdirLocks guards workdirs across workspaces sharing them, e.g. a toolchain
+// purged from cache while in use cleans the workdir its replacement builds from
+var workdirLocks sync.Map
+
+// NewWorkspace creates toolchain of an exercise keyed by id (see Key), workdir starts from template directory when given
+func NewWorkspace(id string, template string, engine container.Engine, config types.BuildConfig) *Workspace {
+	workdir := filepath.Join(os.TempDir(), "kerat", id)
+	mu, _ := workdirLocks.LoadOrStore(workdir, &sync.RWMutex{})
+
+	return &Workspace{
+		id:       id,
+		workdir:  workdir,
+		template: template,
+		engine:   engine,
+		config:   config,
+		mu:       mu.(*sync.RWMutex),
+	}
+}
+
+// Prep warms exercise workdir from template once, it is never written afterwards
+func (ws *Workspace) Prep() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	return ws.prep()
+}
+
+// prep is Prep with ws.mu held
+func (ws *Workspace) prep() error {
+	if !util.IsNotExist(ws.workdir) {
+		return nil
+	}
+
+	parent := filepath.Dir(ws.workdir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+
+	// concurrent engine may prep the same exercise, only publish complete copy
+	tmp, err := os.MkdirTemp(parent, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	// no template, sources are all there is
+	if ws.template != "" {
+		if err := os.CopyFS(tmp, os.DirFS(ws.template)); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmp, ws.workdir); err != nil && util.IsNotExist(ws.workdir) {
+		return err
+	}
+
+	return nil
+}
+
+// Build runs the build container against a copy of workdir with sources written into it,
+// a failed build is expected to exit with non-zero code and report diagnostics
+func (ws *Workspace) Build(ctx context.Context, source types.SourceCode, config types.SubmissionConfig) (types.Build, error) {
+	buildConfig := ws.config.SubmissionConfig
+
+	// build command decides what sanitizing means, e.g. ASan and UBSan for C
+	if config.Sanitize {
+		buildConfig.Env = maps.Clone(buildConfig.Env)
+		if buildConfig.Env == nil {
+			buildConfig.Env = map[string]string{}
+		}
+		buildConfig.Env["KERAT_SANITIZE"] = "1"
+	}
+
+	builddir, err := os.MkdirTemp(os.TempDir(), "kerat-"+ws.id+"-")
+	if err != nil {
+		return types.Build{}, err
+	}
+	defer os.RemoveAll(builddir)
+
+	if err := ws.link(builddir); err != nil {
+		return types.Build{}, fmt.Errorf("failed to copy workdir: %w", err)
+	}
+
+	// write source codes to builddir
+	sources := append(source.Src, source.SrcTest...)
+	for _, v := range sources {
+		filePath := filepath.Join(builddir, v.Filename)
+		if err := util.ReplaceFile(filePath, []byte(v.SourceCode), 0644); err != nil {
+			return types.Build{}, err
+		}
+	}
+
+	content, err := util.TarDirectory(builddir)
+	if err != nil {
+		return types.Build{}, fmt.Errorf("creating tar error: %w", err)
+	}
+
+	containerId, err := ws.engine.Create(ctx, buildConfig)
+	if err != nil {
+		return types.Build{}, fmt.Errorf("build container creation error: %w", err)
+	}
+
+	defer func() {
+		go ws.engine.Remove(containerId)
+	}()
+
+	copyPayload := types.CopyPayload{ContainerId: containerId, Dest: buildConfig.Workdir, Content: &content}
+	if err := ws.engine.Copy(ctx, copyPayload); err != nil {
+		return types.Build{}, fmt.Errorf("copying tar error: %w", err)
+	}
+
+	rt, err := ws.engine.Execute(ctx, types.RunPayload{ContainerId: containerId, Config: buildConfig})
+	if err != nil {
+		return types.Build{}, fmt.Errorf("compiler stopped working: %w", err)
+	}
+
+	if rt.Metrics.ExitCode != 0 {
+		build := types.Build{Stderr: rt.Stderr, Stdout: rt.Stdout}
+
+		// compiler is killed without a word
+		if rt.Metrics.OOMKilled {
+			build.Stderr = fmt.Appendf(build.Stderr, "\nbuild exceeded memory limit of %d MiB\n", ws.config.MaxMemory)
+		}
+		return build, nil
+	}
+
+	artifact, err := ws.engine.CopyFrom(ctx, containerId, ws.config.Artifact)
+	if err != nil {
+		return types.Build{}, fmt.Errorf("failed to copy build artifact: %w", err)
+	}
+	defer artifact.Close()
+
+	// kept as TAR, so directories work as well
+	archive, err := io.ReadAll(artifact)
+	if err != nil {
+		return types.Build{}, fmt.Errorf("failed to read build artifact: %w", err)
+	}
+
+	build := types.Build{
+		Success:  true,
+		Artifact: archive,
+	}
+
+	return build, nil
+}
+
+// link hard links workdir into dst. Workdir may be cleaned by cache eviction,
+// even before the workspace is cached by a concurrent submission, it is prepped again then
+func (ws *Workspace) link(dst string) error {
+	ws.mu.RLock()
+	if !util.IsNotExist(ws.workdir) {
+		defer ws.mu.RUnlock()
+		return util.LinkTree(ws.workdir, dst)
+	}
+	ws.mu.RUnlock()
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if err := ws.prep(); err != nil {
+		return err
+	}
+
+	return util.LinkTree(ws.workdir, dst)
+}
+
+func (ws *Workspace) Size() (int64, error) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return util.DirSize(ws.workdir)
+}
+
+// nuke workdir
+func (ws *Workspace) Clean() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	return os.RemoveAll(ws.workdir)
+}
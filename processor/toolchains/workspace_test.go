@@ -0,0 +1,73 @@
+package toolchains
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codeberg.org/iklabib/kerat/processor/container/containertest"
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+func TestBuildAfterClean(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	template := t.TempDir()
+	if err := os.WriteFile(filepath.Join(template, "box.csproj"), []byte("<Project />"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := types.BuildConfig{
+		SubmissionConfig: types.SubmissionConfig{Id: "csharp", ContainerImage: "dotnet-sdk", Workdir: "/workspace"},
+		Artifact:         "/workspace/output/box",
+	}
+
+	engine, err := containertest.NewFake(types.Config{SubmissionConfigs: []types.SubmissionConfig{config.SubmissionConfig}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Script("csharp", containertest.FakeRun{Files: map[string][]byte{"/workspace/output/box": []byte("binary")}})
+
+	ws := NewWorkspace("exercise", template, engine, config)
+	if err := ws.Prep(); err != nil {
+		t.Fatal(err)
+	}
+
+	// evicted right after being prepped, before the build gets to it
+	if err := ws.Clean(); err != nil {
+		t.Fatal(err)
+	}
+
+	source := types.SourceCode{Src: []types.SourceFile{{Filename: "Main.cs", SourceCode: "class Main {}"}}}
+	build, err := ws.Build(context.Background(), source, config.SubmissionConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !build.Success {
+		t.Fatalf("build failed: %s", build.Stderr)
+	}
+
+	tr := tar.NewReader(bytes.NewReader(build.Artifact))
+	header, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := io.ReadAll(tr); header.Name != "box" || string(content) != "binary" {
+		t.Errorf("artifact %s with %q", header.Name, content)
+	}
+
+	size, err := ws.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size == 0 {
+		t.Error("workdir is not prepped again")
+	}
+}
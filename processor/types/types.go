@@ -3,22 +3,63 @@ package types
 import "io"
 
 type SubmissionConfig struct {
-	Id             string           `json:"id" yaml:"id"`
-	CPUPeriod      int64            `json:"cpu_period" yaml:"cpu_period"`
-	CPUQuota       int64            `json:"cpu_quota" yaml:"cpu_quota"`
-	MaxPids        int64            `json:"max_pids" yaml:"max_pids"`
-	MaxSwap        int64            `json:"max_swap" yaml:"max_swap"`     // MiB
-	MaxMemory      int64            `json:"max_memory" yaml:"max_memory"` // MiB
-	Timeout        int              `json:"timeout" yaml:"timeout"`       // wall-time in seconds
-	Ulimits        map[string]int64 `json:"ulimits" yaml:"ulimits"`
-	ContainerImage string           `json:"container_image" yaml:"container_image"`
-	EntryPoint     []string         `json:"entry_point" yaml:"entry_point"`
+	Id             string            `json:"id" yaml:"id"`
+	Kind           Kind              `json:"kind" yaml:"kind"` // inferred from build when empty
+	CPUPeriod      int64             `json:"cpu_period" yaml:"cpu_period"`
+	CPUQuota       int64             `json:"cpu_quota" yaml:"cpu_quota"`
+	MaxPids        int64             `json:"max_pids" yaml:"max_pids"`
+	MaxSwap        int64             `json:"max_swap" yaml:"max_swap"`             // MiB
+	MaxMemory      int64             `json:"max_memory" yaml:"max_memory"`         // MiB
+	MaxOutput      int64             `json:"max_output" yaml:"max_output"`         // KiB of stdout and stderr combined
+	Timeout        int               `json:"timeout" yaml:"timeout"`               // wall-time in seconds
+	CPUTimeLimit   int64             `json:"cpu_time_limit" yaml:"cpu_time_limit"` // seconds of CPU time, unbounded when zero
+	TestTimeout    int               `json:"test_timeout" yaml:"test_timeout"`     // wall-time in seconds of each test, enforced by harness, unbounded when zero
+	Ulimits        map[string]int64  `json:"ulimits" yaml:"ulimits"`
+	ContainerImage string            `json:"container_image" yaml:"container_image"`
+	EntryPoint     []string          `json:"entry_point" yaml:"entry_point"`
+	Env            map[string]string `json:"env" yaml:"env"`
+	Workdir        string            `json:"workdir" yaml:"workdir"`             // sources or build artifact are copied here, /workspace by default
+	ResultFormat   ResultFormat      `json:"result_format" yaml:"result_format"` // json by default
+	PoolSize       int               `json:"pool_size" yaml:"pool_size"`         // pre-created containers
+	Build          *BuildConfig      `json:"build" yaml:"build"`                 // compiled submissions only
+	Sanitize       bool              `json:"sanitize" yaml:"sanitize"`           // build with KERAT_SANITIZE set, e.g. ASan and UBSan for C
+	Reserved       []string          `json:"reserved" yaml:"reserved"`           // harness files next to the sources, build template files are reserved as well
+}
+
+// Kind tells how a submission type is graded
+type Kind string
+
+const (
+	KindInterpreted Kind = "interpreted" // sources are copied to the submission container
+	KindCompiled    Kind = "compiled"    // sources go to the build container, its artifact to the submission container
+)
+
+// ResultFormat tells how the submission container reports results
+type ResultFormat string
+
+const (
+	ResultJSON       ResultFormat = "json"       // harness prints ContainerResult to stdout
+	ResultExitCode   ResultFormat = "exit_code"  // a single test, passed on zero exit code
+	ResultStdio      ResultFormat = "stdio"      // program runs once per test case, its stdout is compared
+	ResultChecker    ResultFormat = "checker"    // runs exercise checkers, never submissions
+	ResultInteractor ResultFormat = "interactor" // runs exercise interactors, never submissions
+)
+
+// BuildConfig describes the container compiling submissions
+type BuildConfig struct {
+	SubmissionConfig `yaml:",inline"`
+	Template         string `json:"template" yaml:"template"` // directory under repository the workdir starts from, none when empty
+	Artifact         string `json:"artifact" yaml:"artifact"` // file or directory copied to submission container
 }
 
 type Config struct {
 	Repository        string             `json:"repository" yaml:"repository"`
+	Exercises         string             `json:"exercises" yaml:"exercises"`
 	QueueCap          int                `json:"queue_cap" yaml:"queue_cap"`
 	CleanInterval     int                `json:"clean_interval" yaml:"clean_interval"`
+	JobRetention      int                `json:"job_retention" yaml:"job_retention"` // minutes finished asynchronous submissions are kept
+	CacheMaxEntries   int                `json:"cache_max_entries" yaml:"cache_max_entries"`
+	CacheMaxSize      int64              `json:"cache_max_size" yaml:"cache_max_size"` // MiB
 	Engine            string             `json:"engine" yaml:"engine"`
 	Runtime           string             `json:"runtime" yaml:"runtime"`
 	SubmissionConfigs []SubmissionConfig `json:"submission_configs" yaml:"submission_configs"`
@@ -34,34 +75,141 @@ type SourceFile struct {
 	SourceCode string `json:"src"`
 }
 
+type Exercise struct {
+	Id         string           `json:"id" yaml:"id"`
+	Version    int              `json:"version" yaml:"-"`
+	Type       string           `json:"subtype" yaml:"subtype"`
+	Config     SubmissionConfig `json:"config" yaml:"config"` // resource overrides, zero values inherit from submission type
+	Tests      []SourceFile     `json:"tests" yaml:"-"`       // hidden from students
+	Templates  []SourceFile     `json:"templates" yaml:"-"`   // starter code
+	Solution   []SourceFile     `json:"solution" yaml:"-"`    // reference solution, hidden from students
+	Cases      []TestCase       `json:"cases" yaml:"-"`       // stdio submission types only, hidden from students
+	Checker    *Checker         `json:"checker,omitempty" yaml:"checker,omitempty"`
+	Interactor *Checker         `json:"interactor,omitempty" yaml:"interactor,omitempty"` // talks to the program through its stdin and stdout
+}
+
+// Checker is an exercise program judging stdio submissions, either by their
+// output when there are many valid answers, or by talking to them as interactor
+type Checker struct {
+	Type   string       `json:"subtype" yaml:"subtype"` // submission type with checker or interactor result format
+	Source []SourceFile `json:"source" yaml:"-"`
+}
+
+// TestCase is fed to the program through stdin, its stdout is compared to Output
+type TestCase struct {
+	Input   string     `json:"input"`
+	Output  string     `json:"output"`
+	Compare Comparison `json:"compare"`
+}
+
+// Comparison tells how output of a test case is compared to the expected one
+type Comparison struct {
+	Mode       CompareMode `json:"mode" yaml:"mode"`               // trailing_whitespace when empty
+	AbsEpsilon float64     `json:"abs_epsilon" yaml:"abs_epsilon"` // numeric only
+	RelEpsilon float64     `json:"rel_epsilon" yaml:"rel_epsilon"` // numeric only
+}
+
+type CompareMode string
+
+const (
+	CompareExact              CompareMode = "exact"
+	CompareTrailingWhitespace CompareMode = "trailing_whitespace" // trailing spaces of lines and trailing blank lines are ignored
+	CompareTokens             CompareMode = "tokens"              // whitespace separated tokens
+	CompareNumeric            CompareMode = "numeric"             // tokens, numbers are equal within epsilon
+	CompareUnorderedLines     CompareMode = "unordered_lines"     // lines in any order
+	CompareRegex              CompareMode = "regex"               // expected output is a pattern the whole output matches
+)
+
 type Submission struct {
 	ExerciseId string     `json:"id"`
+	Version    int        `json:"version"` // exercise version, latest if zero, only honored for instructors
 	Type       string     `json:"subtype"`
 	Source     SourceCode `json:"source"`
+	Cases      []TestCase `json:"-"` // taken from the exercise
+	Checker    *Checker   `json:"-"`
+	Interactor *Checker   `json:"-"`
 }
 
 type Build struct {
-	Success bool
-	BinPath string
-	Stderr  []byte
-	Stdout  []byte
+	Success  bool
+	Artifact []byte // build artifact as TAR
+	Stderr   []byte
+	Stdout   []byte
 }
 
 type Runtime struct {
-	Stdout []byte `json:"stdout"`
-	Stderr []byte `json:"stderr"`
+	Stdout  []byte  `json:"stdout"`
+	Stderr  []byte  `json:"stderr"`
+	Metrics Metrics `json:"metrics"`
+}
+
+type SubmissionStatus string
+
+const (
+	StatusQueued   SubmissionStatus = "queued"
+	StatusBuilding SubmissionStatus = "building"
+	StatusRunning  SubmissionStatus = "running"
+	StatusDone     SubmissionStatus = "done"
+	StatusFailed   SubmissionStatus = "failed"
+)
+
+type EventType string
+
+const (
+	EventQueued  EventType = "queued"  // waiting for a free slot, carries queue position
+	EventPrep    EventType = "prep"    // preparing toolchain
+	EventBuild   EventType = "build"   // build finished, carries compiler diagnostics
+	EventStarted EventType = "started" // submission container started
+	EventTest    EventType = "test"    // a test result
+	EventDone    EventType = "done"    // carries the final result and metrics
+	EventFailed  EventType = "failed"  // processing error
+)
+
+// Event is a progress update of a submission
+type Event struct {
+	Type     EventType         `json:"type"`
+	Status   SubmissionStatus  `json:"status"`
+	Position int               `json:"position,omitempty"`
+	Success  *bool             `json:"success,omitempty"` // build success
+	Build    string            `json:"build,omitempty"`   // compiler diagnostics
+	Test     *TestResult       `json:"test,omitempty"`
+	Result   *SubmissionResult `json:"result,omitempty"`
+	Error    string            `json:"error,omitempty"`
 }
 
 type SubmissionResult struct {
-	Success bool         `json:"success"`
-	Build   string       `json:"build"`
-	Tests   []TestResult `json:"tests"`
-	Metrics Metrics      `json:"metrics"`
+	ExerciseVersion int               `json:"exercise_version"`
+	Verdict         SubmissionVerdict `json:"verdict"`
+	Message         string            `json:"message,omitempty"` // explains verdicts without test results
+	Success         bool              `json:"success"`
+	Build           string            `json:"build"`
+	Tests           []TestResult      `json:"tests"`
+	Metrics         Metrics           `json:"metrics"`
 }
 
+// SubmissionVerdict is the outcome of a whole submission, only internal_error
+// is not caused by the submission itself
+type SubmissionVerdict string
+
+const (
+	SubmissionOK            SubmissionVerdict = "ok"
+	SubmissionCompileError  SubmissionVerdict = "compile_error"
+	SubmissionTestFailure   SubmissionVerdict = "test_failure"
+	SubmissionTimeLimit     SubmissionVerdict = "time_limit"
+	SubmissionCPUTimeLimit  SubmissionVerdict = "cpu_time_limit"
+	SubmissionMemoryLimit   SubmissionVerdict = "memory_limit"
+	SubmissionOutputLimit   SubmissionVerdict = "output_limit"
+	SubmissionRuntimeError  SubmissionVerdict = "runtime_error"
+	SubmissionHarnessError  SubmissionVerdict = "harness_error" // harness output is unreadable, e.g. submission wrote to it
+	SubmissionInternalError SubmissionVerdict = "internal_error"
+)
+
 type RunPayload struct {
-	ContainerId    string
-	SubmissionType string
+	ContainerId string
+	Config      SubmissionConfig
+	Stdin       io.Reader        // needs a container created with stdio result format
+	Stdout      io.Writer        // streams stdout while running instead of collecting it
+	OnTest      func(TestResult) // json harnesses only, called as every test completes
 }
 
 type CopyPayload struct {
@@ -71,12 +219,28 @@ type CopyPayload struct {
 }
 
 type TestResult struct {
-	Passed     bool   `json:"passed"`
-	Name       string `json:"name"`
-	Message    string `json:"message"`
-	StackTrace string `json:"stack_trace"`
+	Passed     bool     `json:"passed"`
+	Name       string   `json:"name"`
+	Verdict    Verdict  `json:"verdict,omitempty"` // stdio submission types, and harnesses reporting a timed out test
+	Score      *float64 `json:"score,omitempty"`   // between 0 and 1, exercises with checker only
+	Message    string   `json:"message"`
+	StackTrace string   `json:"stack_trace"`
+	Duration   float64  `json:"duration"` // wall time (s), zero when harness does not report it
 }
 
+type Verdict string
+
+const (
+	VerdictAccepted            Verdict = "accepted"
+	VerdictWrongAnswer         Verdict = "wrong_answer"
+	VerdictTimeLimitExceeded   Verdict = "time_limit_exceeded"
+	VerdictRuntimeError        Verdict = "runtime_error"
+	VerdictMemoryLimitExceeded Verdict = "memory_limit_exceeded"
+	VerdictOutputLimitExceeded Verdict = "output_limit_exceeded"
+	VerdictCPUTimeExceeded     Verdict = "cpu_time_limit_exceeded"
+	VerdictPartial             Verdict = "partially_accepted"
+)
+
 type ContainerResult struct {
 	Success bool         `json:"success"`
 	Message string       `json:"message"`
@@ -85,8 +249,11 @@ type ContainerResult struct {
 }
 
 type Metrics struct {
-	ExitCode int64   `json:"exit_code"`
-	WallTime float64 `json:"wall_time"` // running wall time (s)
-	CpuTime  uint64  `json:"cpu_time"`  // total CPU time consumed (ns)
-	Memory   uint64  `json:"memory"`    // peak memory recorded (bytes)
+	ExitCode        int64   `json:"exit_code"`
+	WallTime        float64 `json:"wall_time"`         // running wall time (s)
+	CpuTime         uint64  `json:"cpu_time"`          // total CPU time consumed (ns)
+	Memory          uint64  `json:"memory"`            // peak memory recorded (bytes)
+	OOMKilled       bool    `json:"oom_killed"`        // killed for exceeding max_memory
+	OutputTruncated bool    `json:"output_truncated"`  // killed for exceeding max_output
+	CPUTimeExceeded bool    `json:"cpu_time_exceeded"` // killed for exceeding cpu_time_limit
 }
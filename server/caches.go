@@ -0,0 +1,31 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (s *HTTPServer) HandleCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.processor.Caches().Stats())
+}
+
+func (s *HTTPServer) HandlePurgeCache(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if !s.processor.Caches().Purge(id) {
+		http.Error(w, "cache entry not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) HandlePurgeCaches(w http.ResponseWriter, r *http.Request) {
+	purged := s.processor.Caches().PurgeAll()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Purged int `json:"purged"`
+	}{purged})
+}
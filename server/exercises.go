@@ -0,0 +1,217 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"codeberg.org/iklabib/kerat/processor"
+	"codeberg.org/iklabib/kerat/processor/exercise"
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+// RequireToken guards instructor endpoints with a bearer token,
+// endpoints are disabled when no token is configured
+func RequireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if !authorized(token, r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// authorized tells whether the request carries the instructor token
+func authorized(token string, r *http.Request) bool {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+func (s *HTTPServer) HandleCreateExercise(w http.ResponseWriter, r *http.Request) {
+	ex, ok := s.decodeAndValidateExercise(w, r)
+	if !ok {
+		return
+	}
+
+	// skip the costly verification when it is going to be rejected anyway
+	_, err := s.processor.Exercises().Load(ex.Id)
+	switch {
+	case err == nil:
+		http.Error(w, exercise.ErrExists.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, exercise.ErrInvalidId):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case !errors.Is(err, exercise.ErrNotFound):
+		// e.g. leftovers of a broken exercise, creating over it would fail as well
+		log.Printf("[%s] failed to load exercise: %v\n", ex.Id, err)
+		http.Error(w, "exercise id is taken by an unreadable exercise", http.StatusBadRequest)
+		return
+	}
+
+	if !s.verifySolution(w, r, ex) {
+		return
+	}
+
+	ex, err = s.processor.Exercises().Create(ex)
+	if err != nil {
+		writeExerciseError(w, ex.Id, "create", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ex)
+}
+
+func (s *HTTPServer) HandleUpdateExercise(w http.ResponseWriter, r *http.Request) {
+	ex, ok := s.decodeAndValidateExercise(w, r)
+	if !ok {
+		return
+	}
+
+	// skip the costly verification when there is nothing to update
+	if _, err := s.processor.Exercises().Load(ex.Id); err != nil {
+		writeExerciseError(w, ex.Id, "load", err)
+		return
+	}
+
+	if !s.verifySolution(w, r, ex) {
+		return
+	}
+
+	ex, err := s.processor.Exercises().Update(ex)
+	if err != nil {
+		writeExerciseError(w, ex.Id, "update", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ex)
+}
+
+func (s *HTTPServer) HandleGetExercise(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var ex types.Exercise
+	var err error
+	if v := r.PathValue("version"); v != "" {
+		version, convErr := strconv.Atoi(v)
+		if convErr != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		ex, err = s.processor.Exercises().LoadVersion(id, version)
+	} else {
+		ex, err = s.processor.Exercises().Load(id)
+	}
+
+	if err != nil {
+		writeExerciseError(w, id, "load", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ex)
+}
+
+func (s *HTTPServer) HandleExerciseVersions(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	versions, err := s.processor.Exercises().Versions(id)
+	if err != nil {
+		writeExerciseError(w, id, "list versions of", err)
+		return
+	}
+
+	if len(versions) == 0 {
+		http.Error(w, exercise.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+func (s *HTTPServer) HandleDeleteExercise(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	err := s.processor.Exercises().Delete(id)
+	if err != nil {
+		writeExerciseError(w, id, "delete", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) decodeAndValidateExercise(w http.ResponseWriter, r *http.Request) (types.Exercise, bool) {
+	var ex types.Exercise
+	if err := json.NewDecoder(r.Body).Decode(&ex); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return ex, false
+	}
+	ex.Id = r.PathValue("id")
+
+	if err := s.processor.ValidateExercise(ex); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return ex, false
+	}
+
+	return ex, true
+}
+
+// verifySolution runs the reference solution, it takes a slot from the submission queue
+func (s *HTTPServer) verifySolution(w http.ResponseWriter, r *http.Request, ex types.Exercise) bool {
+	select {
+	case s.queue <- ex.Id:
+		defer func() { <-s.queue }()
+	case <-r.Context().Done():
+		s.handleContextCancellation(w, r, ex.Id)
+		return false
+	}
+
+	err := s.processor.VerifySolution(r.Context(), ex)
+
+	var refErr *processor.ReferenceError
+	if errors.As(err, &refErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(struct {
+			Error  string                 `json:"error"`
+			Result types.SubmissionResult `json:"result"`
+		}{refErr.Error(), refErr.Result})
+		return false
+	} else if err != nil {
+		log.Printf("[%s] failed to verify reference solution: %v\n", ex.Id, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
+func writeExerciseError(w http.ResponseWriter, id string, action string, err error) {
+	switch {
+	case errors.Is(err, exercise.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, exercise.ErrExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, exercise.ErrInvalidId):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("[%s] failed to %s exercise: %v\n", id, action, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+type Job struct {
+	Id        string                  `json:"id"`
+	Status    types.SubmissionStatus  `json:"status"`
+	Result    *types.SubmissionResult `json:"result,omitempty"`
+	Error     string                  `json:"error,omitempty"`
+	CreatedAt time.Time               `json:"created_at"`
+	UpdatedAt time.Time               `json:"updated_at"`
+
+	events      []types.Event
+	subscribers []chan types.Event
+}
+
+// Jobs keeps track of asynchronous submissions,
+// finished jobs are forgotten after retention minutes
+type Jobs struct {
+	retention int
+	mu        sync.RWMutex
+	jobs      map[string]*Job
+	waiting   []string
+}
+
+func NewJobs(retention int) *Jobs {
+	return &Jobs{
+		retention: retention,
+		mu:        sync.RWMutex{},
+		jobs:      make(map[string]*Job),
+		waiting:   []string{},
+	}
+}
+
+func (j *Jobs) Add(id string) Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	job := &Job{
+		Id:        id,
+		Status:    types.StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	j.jobs[id] = job
+
+	j.waiting = append(j.waiting, id)
+	j.publish(job, types.Event{Type: types.EventQueued, Status: types.StatusQueued, Position: len(j.waiting)})
+
+	return job.snapshot()
+}
+
+func (j *Jobs) Get(id string) (Job, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+
+	return job.snapshot(), true
+}
+
+// Dequeue marks the job as no longer waiting for a slot,
+// jobs behind it are notified of their new position
+func (j *Jobs) Dequeue(id string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	idx := slices.Index(j.waiting, id)
+	if idx < 0 {
+		return
+	}
+	j.waiting = slices.Delete(j.waiting, idx, idx+1)
+
+	for i := idx; i < len(j.waiting); i++ {
+		if job, ok := j.jobs[j.waiting[i]]; ok {
+			j.publish(job, types.Event{Type: types.EventQueued, Status: types.StatusQueued, Position: i + 1})
+		}
+	}
+}
+
+func (j *Jobs) Publish(id string, event types.Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if job, ok := j.jobs[id]; ok {
+		j.publish(job, event)
+	}
+}
+
+func (j *Jobs) Finish(id string, result types.SubmissionResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if job, ok := j.jobs[id]; ok {
+		job.Result = &result
+		j.publish(job, types.Event{Type: types.EventDone, Status: types.StatusDone, Result: &result})
+	}
+
+	j.expire(id)
+}
+
+// Fail finishes the job with message shown to clients, not the raw error
+func (j *Jobs) Fail(id string, message string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if job, ok := j.jobs[id]; ok {
+		job.Error = message
+		j.publish(job, types.Event{Type: types.EventFailed, Status: types.StatusFailed, Error: message})
+	}
+
+	j.expire(id)
+}
+
+// Subscribe replays past events of the job and streams the following ones,
+// channel is closed once the job is finished or unsubscribe is called
+func (j *Jobs) Subscribe(id string) (<-chan types.Event, func(), bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return nil, nil, false
+	}
+
+	// large enough to never block publisher on a finished job
+	ch := make(chan types.Event, len(job.events)+256)
+	for _, event := range job.events {
+		ch <- event
+	}
+
+	if job.finished() {
+		close(ch)
+		return ch, func() {}, true
+	}
+
+	job.subscribers = append(job.subscribers, ch)
+	unsubscribe := func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		if idx := slices.Index(job.subscribers, ch); idx >= 0 {
+			job.subscribers = slices.Delete(job.subscribers, idx, idx+1)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe, true
+}
+
+// caller must hold the lock
+func (j *Jobs) publish(job *Job, event types.Event) {
+	job.Status = event.Status
+	job.UpdatedAt = time.Now()
+	job.events = append(job.events, event)
+
+	// slow subscriber is cut off rather than stall the submission or miss events,
+	// it is told the final state or reconnects to replay
+	job.subscribers = slices.DeleteFunc(job.subscribers, func(ch chan types.Event) bool {
+		select {
+		case ch <- event:
+			return false
+		default:
+			close(ch)
+			return true
+		}
+	})
+
+	if job.finished() {
+		for _, ch := range job.subscribers {
+			close(ch)
+		}
+		job.subscribers = nil
+	}
+}
+
+func (j *Jobs) expire(id string) {
+	retention := time.Duration(j.retention) * time.Minute
+	time.AfterFunc(retention, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		delete(j.jobs, id)
+	})
+}
+
+func (job *Job) finished() bool {
+	return job.Status == types.StatusDone || job.Status == types.StatusFailed
+}
+
+func (job *Job) snapshot() Job {
+	return Job{
+		Id:        job.Id,
+		Status:    job.Status,
+		Result:    job.Result,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"sync"
 
 	"codeberg.org/iklabib/kerat/processor"
+	"codeberg.org/iklabib/kerat/processor/exercise"
 	"codeberg.org/iklabib/kerat/processor/types"
 	gonanoid "github.com/matoous/go-nanoid/v2"
 )
@@ -17,12 +20,19 @@ var ALPHABET string = "abcdefghijklmnopqrstuvwxyz0123456789"
 type HTTPServer struct {
 	processor *processor.SubmissionProcessor
 	queue     chan string
+	waiting   chan struct{} // asynchronous submissions waiting for a queue slot
+	jobs      *Jobs
+	token     string // instructor token, see RequireToken
+	inflight  sync.WaitGroup
 }
 
-func NewHTTPServer(processor *processor.SubmissionProcessor, queueCap int) *HTTPServer {
+func NewHTTPServer(processor *processor.SubmissionProcessor, queueCap int, retention int, token string) *HTTPServer {
 	return &HTTPServer{
 		processor: processor,
 		queue:     make(chan string, queueCap),
+		waiting:   make(chan struct{}, queueCap),
+		jobs:      NewJobs(retention),
+		token:     token,
 	}
 }
 
@@ -38,10 +48,20 @@ func (s *HTTPServer) HandleSubmission(w http.ResponseWriter, r *http.Request) {
 	case s.queue <- submissionId:
 		defer func() { <-s.queue }()
 
-		result, err := s.processor.ProcessSubmission(r.Context(), submission, submissionId)
+		// verdicts caused by the submission are not errors, they are still 200
+		result, err := s.processor.ProcessSubmission(r.Context(), submission, submissionId, nil)
 		if err != nil {
+			status, message := submissionError(err)
+			if status != http.StatusInternalServerError {
+				http.Error(w, message, status)
+				return
+			}
+
 			log.Printf("[%s] processing error: %v\n", submissionId, err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			result.Verdict = types.SubmissionInternalError
+			result.Message = message
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(result)
 			return
 		}
 
@@ -52,6 +72,160 @@ func (s *HTTPServer) HandleSubmission(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleSubmissionAsync enqueues the submission and responds right away,
+// result is fetched later through HandleSubmissionStatus
+func (s *HTTPServer) HandleSubmissionAsync(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	submission, submissionId, ok := s.decodeAndValidateSubmission(w, r)
+	if !ok {
+		return
+	}
+
+	// bounded like running submissions, excess is turned away instead of piling up
+	select {
+	case s.waiting <- struct{}{}:
+	default:
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "submission queue is full", http.StatusServiceUnavailable)
+		return
+	}
+
+	job := s.jobs.Add(submissionId)
+	s.inflight.Add(1)
+	go s.processJob(submission, submissionId)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+func (s *HTTPServer) HandleSubmissionStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	job, ok := s.jobs.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "submission not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(job)
+}
+
+// HandleSubmissionEvents streams submission progress as Server-Sent Events
+func (s *HTTPServer) HandleSubmissionEvents(w http.ResponseWriter, r *http.Request) {
+	submissionId := r.PathValue("id")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe, ok := s.jobs.Subscribe(submissionId)
+	if !ok {
+		http.Error(w, "submission not found", http.StatusNotFound)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var last types.Event
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// make sure client always receives the final event, a client cut off
+				// for falling behind on a running job has to reconnect
+				if last.Type != types.EventDone && last.Type != types.EventFailed {
+					if job, ok := s.jobs.Get(submissionId); ok && job.finished() {
+						writeEvent(w, types.Event{Type: types.EventType(job.Status), Status: job.Status, Result: job.Result, Error: job.Error})
+						flusher.Flush()
+					}
+				}
+				return
+			}
+
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+			last = event
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event types.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// processJob is detached from the request, a client hanging up does not discard the result
+func (s *HTTPServer) processJob(submission types.Submission, submissionId string) {
+	defer s.inflight.Done()
+
+	s.queue <- submissionId
+	defer func() { <-s.queue }()
+	<-s.waiting
+	s.jobs.Dequeue(submissionId)
+
+	notify := func(event types.Event) {
+		s.jobs.Publish(submissionId, event)
+	}
+
+	result, err := s.processor.ProcessSubmission(context.Background(), submission, submissionId, notify)
+	if err != nil {
+		log.Printf("[%s] processing error: %v\n", submissionId, err)
+		_, message := submissionError(err)
+		s.jobs.Fail(submissionId, message)
+		return
+	}
+
+	s.jobs.Finish(submissionId, result)
+}
+
+// Wait blocks until asynchronous submissions are done or ctx expires,
+// processor must not be closed while they still use it
+func (s *HTTPServer) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for submissions: %w", ctx.Err())
+	}
+}
+
+// submissionError tells clients what went wrong without leaking internal errors,
+// those are only logged
+func submissionError(err error) (int, string) {
+	switch {
+	case errors.Is(err, exercise.ErrNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, processor.ErrInvalidSubmission), errors.Is(err, exercise.ErrInvalidId):
+		return http.StatusBadRequest, err.Error()
+	default:
+		return http.StatusInternalServerError, "internal server error"
+	}
+}
+
 func (s *HTTPServer) decodeAndValidateSubmission(w http.ResponseWriter, r *http.Request) (types.Submission, string, bool) {
 	var submission types.Submission
 	if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
@@ -59,6 +233,11 @@ func (s *HTTPServer) decodeAndValidateSubmission(w http.ResponseWriter, r *http.
 		return submission, "", false
 	}
 
+	// students are always graded against the latest version, old ones have other hidden tests
+	if !authorized(s.token, r) {
+		submission.Version = 0
+	}
+
 	submissionId, err := gonanoid.Generate(ALPHABET, 8)
 	if err != nil {
 		log.Printf("[error] failed to generate submission ID: %v\n", err)
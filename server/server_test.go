@@ -0,0 +1,402 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"codeberg.org/iklabib/kerat/processor"
+	"codeberg.org/iklabib/kerat/processor/container/containertest"
+	"codeberg.org/iklabib/kerat/processor/types"
+)
+
+const testToken = "secret"
+
+const testConfig = `
+queue_cap: 1
+submission_configs:
+  - id: python
+    container_image: python
+`
+
+// newTestServer serves the routes of cmd/kerat backed by a fake engine
+func newTestServer(t *testing.T) (*HTTPServer, *httptest.Server, *containertest.Fake) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := processor.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Repository = t.TempDir()
+	config.Exercises = t.TempDir()
+
+	engine, err := containertest.NewFake(*config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := processor.NewSubmissionProcessorWithEngine(config, engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewHTTPServer(p, config.QueueCap, config.JobRetention, testToken)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /submit", s.HandleSubmission)
+	mux.HandleFunc("POST /submissions", s.HandleSubmissionAsync)
+	mux.HandleFunc("GET /submissions/{id}", s.HandleSubmissionStatus)
+	mux.HandleFunc("GET /submissions/{id}/events", s.HandleSubmissionEvents)
+
+	mux.HandleFunc("POST /exercises/{id}", RequireToken(testToken, s.HandleCreateExercise))
+	mux.HandleFunc("PUT /exercises/{id}", RequireToken(testToken, s.HandleUpdateExercise))
+	mux.HandleFunc("GET /exercises/{id}", RequireToken(testToken, s.HandleGetExercise))
+	mux.HandleFunc("GET /exercises/{id}/versions", RequireToken(testToken, s.HandleExerciseVersions))
+	mux.HandleFunc("GET /exercises/{id}/versions/{version}", RequireToken(testToken, s.HandleGetExercise))
+	mux.HandleFunc("DELETE /exercises/{id}", RequireToken(testToken, s.HandleDeleteExercise))
+
+	ts := httptest.NewServer(mux)
+
+	// same order as shutting down in cmd/kerat
+	t.Cleanup(func() {
+		ts.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Wait(ctx); err != nil {
+			t.Error(err)
+		}
+
+		p.Close()
+	})
+
+	return s, ts, engine
+}
+
+func testExercise(test string) types.Exercise {
+	return types.Exercise{
+		Type:     "python",
+		Tests:    []types.SourceFile{{Filename: "test_main.py", SourceCode: test}},
+		Solution: []types.SourceFile{{Filename: "main.py", SourceCode: "def add(a, b): return a + b"}},
+	}
+}
+
+func createExercise(t *testing.T, s *HTTPServer, id string) {
+	t.Helper()
+
+	ex := testExercise("")
+	ex.Id = id
+	if _, err := s.processor.Exercises().Create(ex); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func passed(names ...string) containertest.FakeRun {
+	var tests []types.TestResult
+	for _, name := range names {
+		tests = append(tests, types.TestResult{Passed: true, Name: name})
+	}
+
+	return containertest.FakeRun{Result: types.ContainerResult{Success: true, Output: tests}}
+}
+
+func failed(name string) containertest.FakeRun {
+	return containertest.FakeRun{Result: types.ContainerResult{Output: []types.TestResult{{Name: name}}}}
+}
+
+// request sends body as JSON, with the instructor token when authorized
+func request(t *testing.T, method string, url string, body any, authorized bool) *http.Response {
+	t.Helper()
+
+	var content io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authorized {
+		req.Header.Set("Authorization", "Bearer "+testToken)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+
+	return res
+}
+
+func decode[T any](t *testing.T, res *http.Response) T {
+	t.Helper()
+
+	var v T
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func expectStatus(t *testing.T, res *http.Response, want int) {
+	t.Helper()
+
+	if res.StatusCode != want {
+		body, _ := io.ReadAll(res.Body)
+		t.Fatalf("%s %s: status %d, want %d: %s", res.Request.Method, res.Request.URL.Path, res.StatusCode, want, body)
+	}
+}
+
+func submission(id string) types.Submission {
+	src := []types.SourceFile{{Filename: "main.py", SourceCode: "def add(a, b): return a + b"}}
+	return types.Submission{ExerciseId: id, Source: types.SourceCode{Src: src}}
+}
+
+func TestSubmit(t *testing.T) {
+	s, ts, engine := newTestServer(t)
+	createExercise(t, s, "add")
+
+	engine.Script("python", passed("test_add"))
+	res := request(t, http.MethodPost, ts.URL+"/submit", submission("add"), false)
+	expectStatus(t, res, http.StatusOK)
+
+	result := decode[types.SubmissionResult](t, res)
+	if result.Verdict != types.SubmissionOK || len(result.Tests) != 1 || result.ExerciseVersion != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// verdicts caused by the submission are still 200
+	engine.Script("python", failed("test_add"))
+	res = request(t, http.MethodPost, ts.URL+"/submit", submission("add"), false)
+	expectStatus(t, res, http.StatusOK)
+
+	if result := decode[types.SubmissionResult](t, res); result.Verdict != types.SubmissionTestFailure {
+		t.Errorf("verdict %q, want %q", result.Verdict, types.SubmissionTestFailure)
+	}
+
+	res = request(t, http.MethodPost, ts.URL+"/submit", submission("missing"), false)
+	expectStatus(t, res, http.StatusNotFound)
+
+	res = request(t, http.MethodPost, ts.URL+"/submit", submission("../add"), false)
+	expectStatus(t, res, http.StatusBadRequest)
+
+	res = request(t, http.MethodPost, ts.URL+"/submit", "not a submission", false)
+	expectStatus(t, res, http.StatusBadRequest)
+}
+
+func TestSubmitAsync(t *testing.T) {
+	s, ts, engine := newTestServer(t)
+	createExercise(t, s, "add")
+
+	engine.Script("python", passed("test_a", "test_b"))
+	res := request(t, http.MethodPost, ts.URL+"/submissions", submission("add"), false)
+	expectStatus(t, res, http.StatusAccepted)
+
+	job := decode[Job](t, res)
+	if job.Id == "" || job.Status != types.StatusQueued {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	res = request(t, http.MethodGet, ts.URL+"/submissions/"+job.Id, nil, false)
+	expectStatus(t, res, http.StatusOK)
+
+	job = decode[Job](t, res)
+	if job.Status != types.StatusDone || job.Result == nil || job.Result.Verdict != types.SubmissionOK {
+		t.Errorf("unexpected job %+v", job)
+	}
+
+	res = request(t, http.MethodGet, ts.URL+"/submissions/unknown", nil, false)
+	expectStatus(t, res, http.StatusNotFound)
+}
+
+func TestSubmitAsyncQueueFull(t *testing.T) {
+	s, ts, engine := newTestServer(t)
+	createExercise(t, s, "add")
+
+	// the only slot is taken, the job waits for it
+	s.queue <- "busy"
+
+	engine.Script("python", passed("test_add"))
+	res := request(t, http.MethodPost, ts.URL+"/submissions", submission("add"), false)
+	expectStatus(t, res, http.StatusAccepted)
+	job := decode[Job](t, res)
+
+	res = request(t, http.MethodPost, ts.URL+"/submissions", submission("add"), false)
+	expectStatus(t, res, http.StatusServiceUnavailable)
+	if res.Header.Get("Retry-After") == "" {
+		t.Error("turned away without Retry-After")
+	}
+
+	res = request(t, http.MethodGet, ts.URL+"/submissions/"+job.Id, nil, false)
+	expectStatus(t, res, http.StatusOK)
+	if job := decode[Job](t, res); job.Status != types.StatusQueued {
+		t.Errorf("status %q while waiting for a slot", job.Status)
+	}
+
+	<-s.queue
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if job, _ := s.jobs.Get(job.Id); job.Status != types.StatusDone {
+		t.Errorf("status %q after the slot is free", job.Status)
+	}
+}
+
+func TestSubmissionEventsReplay(t *testing.T) {
+	s, ts, engine := newTestServer(t)
+	createExercise(t, s, "add")
+
+	engine.Script("python", passed("test_a", "test_b"))
+	res := request(t, http.MethodPost, ts.URL+"/submissions", submission("add"), false)
+	expectStatus(t, res, http.StatusAccepted)
+	job := decode[Job](t, res)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// subscribing after the job is finished replays every event and ends the stream
+	res = request(t, http.MethodGet, ts.URL+"/submissions/"+job.Id+"/events", nil, false)
+	expectStatus(t, res, http.StatusOK)
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("content type %q", ct)
+	}
+
+	var names []string
+	var events []types.Event
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			names = append(names, name)
+		} else if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			var event types.Event
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, event)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"queued", "started", "test", "test", "done"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Fatalf("events %v, want %v", names, want)
+	}
+
+	if events[2].Test == nil || events[2].Test.Name != "test_a" {
+		t.Errorf("first test event %+v", events[2])
+	}
+
+	if done := events[len(events)-1]; done.Result == nil || done.Result.Verdict != types.SubmissionOK {
+		t.Errorf("done event %+v", done)
+	}
+
+	res = request(t, http.MethodGet, ts.URL+"/submissions/unknown/events", nil, false)
+	expectStatus(t, res, http.StatusNotFound)
+}
+
+func TestExercises(t *testing.T) {
+	_, ts, engine := newTestServer(t)
+	url := ts.URL + "/exercises/add"
+
+	res := request(t, http.MethodPost, url, testExercise("v1"), false)
+	expectStatus(t, res, http.StatusUnauthorized)
+
+	// reference solution is run before anything is stored
+	engine.Script("python", failed("test_add"))
+	res = request(t, http.MethodPost, url, testExercise("v1"), true)
+	expectStatus(t, res, http.StatusUnprocessableEntity)
+
+	res = request(t, http.MethodGet, url, nil, true)
+	expectStatus(t, res, http.StatusNotFound)
+
+	engine.Script("python", passed("test_add"))
+	res = request(t, http.MethodPost, url, testExercise("v1"), true)
+	expectStatus(t, res, http.StatusCreated)
+	if ex := decode[types.Exercise](t, res); ex.Id != "add" || ex.Version != 1 {
+		t.Errorf("created %s version %d", ex.Id, ex.Version)
+	}
+
+	res = request(t, http.MethodPost, url, testExercise("v1"), true)
+	expectStatus(t, res, http.StatusConflict)
+
+	res = request(t, http.MethodPost, ts.URL+"/exercises/empty", types.Exercise{Type: "python"}, true)
+	expectStatus(t, res, http.StatusBadRequest)
+
+	res = request(t, http.MethodPut, ts.URL+"/exercises/missing", testExercise("v1"), true)
+	expectStatus(t, res, http.StatusNotFound)
+
+	engine.Script("python", passed("test_add"))
+	res = request(t, http.MethodPut, url, testExercise("v2"), true)
+	expectStatus(t, res, http.StatusOK)
+	if ex := decode[types.Exercise](t, res); ex.Version != 2 {
+		t.Errorf("updated version %d, want 2", ex.Version)
+	}
+
+	res = request(t, http.MethodGet, url, nil, true)
+	expectStatus(t, res, http.StatusOK)
+	if ex := decode[types.Exercise](t, res); ex.Version != 2 || ex.Tests[0].SourceCode != "v2" {
+		t.Errorf("latest is version %d with test %q", ex.Version, ex.Tests[0].SourceCode)
+	}
+
+	res = request(t, http.MethodGet, url+"/versions", nil, true)
+	expectStatus(t, res, http.StatusOK)
+	if versions := decode[[]int](t, res); len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+		t.Errorf("versions %v", versions)
+	}
+
+	res = request(t, http.MethodGet, url+"/versions/1", nil, true)
+	expectStatus(t, res, http.StatusOK)
+	if ex := decode[types.Exercise](t, res); ex.Version != 1 || ex.Tests[0].SourceCode != "v1" {
+		t.Errorf("pinned is version %d with test %q", ex.Version, ex.Tests[0].SourceCode)
+	}
+
+	res = request(t, http.MethodGet, url+"/versions/first", nil, true)
+	expectStatus(t, res, http.StatusBadRequest)
+
+	res = request(t, http.MethodDelete, url, nil, true)
+	expectStatus(t, res, http.StatusNoContent)
+
+	res = request(t, http.MethodGet, url, nil, true)
+	expectStatus(t, res, http.StatusNotFound)
+
+	res = request(t, http.MethodDelete, url, nil, true)
+	expectStatus(t, res, http.StatusNotFound)
+}
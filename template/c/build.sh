@@ -0,0 +1,11 @@
+#!/bin/sh
+# compiles submission against kerat harness, KERAT_SANITIZE enables ASan and UBSan
+set -e
+
+FLAGS="-std=gnu17 -O2 -g -Wall -I/kerat"
+if [ -n "$KERAT_SANITIZE" ]; then
+    FLAGS="$FLAGS -fsanitize=address,undefined -fno-sanitize-recover=all -fno-omit-frame-pointer -static-libasan -static-libubsan"
+fi
+
+mkdir -p output
+gcc $FLAGS -o output/box *.c /kerat/kerat.c -lm
@@ -0,0 +1,342 @@
+/*
+ * kerat single-header test harness for C and C++
+ *
+ *   #include "kerat.h"
+ *
+ *   TEST(addition) {
+ *       ASSERT_EQ(add(1, 1), 2);
+ *   }
+ *
+ * Every test runs in its own forked process, a crash or sanitizer report
+ * fails that test only. Results are printed to stdout as Kerat JSON.
+ * Exactly one translation unit defines KERAT_IMPLEMENTATION (kerat.c / kerat.cpp).
+ */
+#ifndef KERAT_H
+#define KERAT_H
+
+#include <math.h>
+#include <string.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+typedef void (*kerat_test_fn)(void);
+
+void kerat_register(const char *name, kerat_test_fn fn);
+void kerat_fail(const char *file, int line, const char *fmt, ...)
+    __attribute__((noreturn, format(printf, 3, 4)));
+
+#ifdef __cplusplus
+}
+#endif
+
+#define TEST(name)                                                            \
+    static void kerat_test_##name(void);                                      \
+    __attribute__((constructor)) static void kerat_register_##name(void) {    \
+        kerat_register(#name, kerat_test_##name);                             \
+    }                                                                         \
+    static void kerat_test_##name(void)
+
+#define FAIL(msg) kerat_fail(__FILE__, __LINE__, "%s", (msg))
+
+#define ASSERT_TRUE(cond)                                                     \
+    do {                                                                      \
+        if (!(cond))                                                          \
+            kerat_fail(__FILE__, __LINE__, "expected %s to be true", #cond);  \
+    } while (0)
+
+#define ASSERT_FALSE(cond)                                                    \
+    do {                                                                      \
+        if (cond)                                                             \
+            kerat_fail(__FILE__, __LINE__, "expected %s to be false", #cond); \
+    } while (0)
+
+#define ASSERT_EQ(actual, expected)                                           \
+    do {                                                                      \
+        long long kerat_a_ = (long long)(actual);                             \
+        long long kerat_e_ = (long long)(expected);                           \
+        if (kerat_a_ != kerat_e_)                                             \
+            kerat_fail(__FILE__, __LINE__, "%s: expected %lld, got %lld",     \
+                       #actual, kerat_e_, kerat_a_);                          \
+    } while (0)
+
+#define ASSERT_NE(actual, expected)                                           \
+    do {                                                                      \
+        long long kerat_a_ = (long long)(actual);                             \
+        long long kerat_e_ = (long long)(expected);                           \
+        if (kerat_a_ == kerat_e_)                                             \
+            kerat_fail(__FILE__, __LINE__, "%s: expected not %lld",           \
+                       #actual, kerat_e_);                                    \
+    } while (0)
+
+#define ASSERT_NEAR(actual, expected, eps)                                    \
+    do {                                                                      \
+        double kerat_a_ = (double)(actual);                                   \
+        double kerat_e_ = (double)(expected);                                 \
+        if (fabs(kerat_a_ - kerat_e_) > (eps))                                \
+            kerat_fail(__FILE__, __LINE__, "%s: expected %g, got %g",         \
+                       #actual, kerat_e_, kerat_a_);                          \
+    } while (0)
+
+#define ASSERT_STR_EQ(actual, expected)                                       \
+    do {                                                                      \
+        const char *kerat_a_ = (actual);                                      \
+        const char *kerat_e_ = (expected);                                    \
+        if (kerat_a_ == NULL || strcmp(kerat_a_, kerat_e_) != 0)              \
+            kerat_fail(__FILE__, __LINE__, "%s: expected \"%s\", got \"%s\"", \
+                       #actual, kerat_e_, kerat_a_ ? kerat_a_ : "(null)");    \
+    } while (0)
+
+#ifdef KERAT_IMPLEMENTATION
+
+#include <errno.h>
+#include <poll.h>
+#include <signal.h>
+#include <stdarg.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+#define KERAT_MAX_TESTS 1024
+#define KERAT_MAX_OUTPUT (16 * 1024)
+
+/* sent once the test function returned, a test calling exit(0) never sends it */
+#define KERAT_COMPLETED '\x06'
+
+struct kerat_test {
+    const char *name;
+    kerat_test_fn fn;
+};
+
+static struct kerat_test kerat_tests[KERAT_MAX_TESTS];
+static int kerat_test_count = 0;
+static int kerat_message_fd = -1;
+
+/* leak checking needs ptrace, which is not available inside the sandbox */
+#ifdef __cplusplus
+extern "C"
+#endif
+const char *__asan_default_options(void) { return "detect_leaks=0"; }
+
+void kerat_register(const char *name, kerat_test_fn fn) {
+    if (kerat_test_count < KERAT_MAX_TESTS) {
+        kerat_tests[kerat_test_count].name = name;
+        kerat_tests[kerat_test_count].fn = fn;
+        kerat_test_count++;
+    }
+}
+
+void kerat_fail(const char *file, int line, const char *fmt, ...) {
+    char buf[1024];
+    int n = snprintf(buf, sizeof(buf), "%s:%d: ", file, line);
+
+    va_list args;
+    va_start(args, fmt);
+    vsnprintf(buf + n, sizeof(buf) - n, fmt, args);
+    va_end(args);
+
+    if (kerat_message_fd >= 0) {
+        ssize_t written = write(kerat_message_fd, buf, strlen(buf));
+        (void)written;
+    }
+    _exit(1);
+}
+
+struct kerat_buffer {
+    char data[KERAT_MAX_OUTPUT];
+    size_t len;
+};
+
+static int kerat_drain(int fd, struct kerat_buffer *buf) {
+    char chunk[4096];
+    ssize_t n = read(fd, chunk, sizeof(chunk));
+    if (n <= 0) {
+        return 0;
+    }
+
+    size_t room = sizeof(buf->data) - 1 - buf->len;
+    size_t take = (size_t)n < room ? (size_t)n : room;
+    memcpy(buf->data + buf->len, chunk, take);
+    buf->len += take;
+    buf->data[buf->len] = '\0';
+    return 1;
+}
+
+static void kerat_print_json(const char *s) {
+    putchar('"');
+    for (; *s; s++) {
+        unsigned char c = (unsigned char)*s;
+        switch (c) {
+        case '"': fputs("\\\"", stdout); break;
+        case '\\': fputs("\\\\", stdout); break;
+        case '\n': fputs("\\n", stdout); break;
+        case '\r': fputs("\\r", stdout); break;
+        case '\t': fputs("\\t", stdout); break;
+        default:
+            if (c < 0x20) {
+                printf("\\u%04x", c);
+            } else {
+                putchar(c);
+            }
+        }
+    }
+    putchar('"');
+}
+
+/* first line of the sanitizer report, if any */
+static void kerat_sanitizer_summary(const char *output, char *dest, size_t size) {
+    const char *markers[] = {"ERROR: AddressSanitizer", "runtime error:", "ERROR: LeakSanitizer"};
+    for (size_t i = 0; i < sizeof(markers) / sizeof(markers[0]); i++) {
+        const char *start = strstr(output, markers[i]);
+        if (start == NULL) {
+            continue;
+        }
+
+        const char *end = strchr(start, '\n');
+        size_t len = end ? (size_t)(end - start) : strlen(start);
+        if (len >= size) {
+            len = size - 1;
+        }
+        memcpy(dest, start, len);
+        dest[len] = '\0';
+        return;
+    }
+}
+
+static int kerat_run(const struct kerat_test *test, char *message, size_t message_size,
+                     struct kerat_buffer *output) {
+    int message_pipe[2], output_pipe[2];
+    if (pipe(message_pipe) != 0 || pipe(output_pipe) != 0) {
+        snprintf(message, message_size, "harness error: %s", strerror(errno));
+        return 0;
+    }
+
+    fflush(stdout);
+    fflush(stderr);
+
+    pid_t pid = fork();
+    if (pid < 0) {
+        snprintf(message, message_size, "harness error: %s", strerror(errno));
+        return 0;
+    }
+
+    if (pid == 0) {
+        close(message_pipe[0]);
+        close(output_pipe[0]);
+        kerat_message_fd = message_pipe[1];
+
+        /* stdout is reserved for results */
+        dup2(output_pipe[1], STDOUT_FILENO);
+        dup2(output_pipe[1], STDERR_FILENO);
+        close(output_pipe[1]);
+
+        test->fn();
+
+        char completed = KERAT_COMPLETED;
+        ssize_t written = write(kerat_message_fd, &completed, 1);
+        (void)written;
+
+        fflush(stdout);
+        exit(0);
+    }
+
+    close(message_pipe[1]);
+    close(output_pipe[1]);
+
+    struct kerat_buffer msg = {{0}, 0};
+    struct pollfd fds[2] = {
+        {message_pipe[0], POLLIN, 0},
+        {output_pipe[0], POLLIN, 0},
+    };
+    struct kerat_buffer *bufs[2] = {&msg, output};
+    int open_fds = 2;
+    while (open_fds > 0) {
+        if (poll(fds, 2, -1) < 0) {
+            if (errno == EINTR) {
+                continue;
+            }
+            break;
+        }
+
+        for (int i = 0; i < 2; i++) {
+            if (fds[i].fd >= 0 && fds[i].revents & (POLLIN | POLLHUP | POLLERR)) {
+                if (!kerat_drain(fds[i].fd, bufs[i])) {
+                    close(fds[i].fd);
+                    fds[i].fd = -1;
+                    open_fds--;
+                }
+            }
+        }
+    }
+
+    int status = 0;
+    waitpid(pid, &status, 0);
+
+    int completed = msg.len == 1 && msg.data[0] == KERAT_COMPLETED;
+    if (completed) {
+        msg.len = 0;
+    }
+
+    if (WIFEXITED(status) && WEXITSTATUS(status) == 0) {
+        if (completed) {
+            return 1;
+        }
+
+        snprintf(message, message_size, "exited before the test finished");
+        return 0;
+    }
+
+    if (msg.len > 0) {
+        size_t len = msg.len < message_size - 1 ? msg.len : message_size - 1;
+        memcpy(message, msg.data, len);
+        message[len] = '\0';
+        return 0;
+    }
+
+    kerat_sanitizer_summary(output->data, message, message_size);
+    if (message[0] != '\0') {
+        return 0;
+    }
+
+    if (WIFSIGNALED(status)) {
+        snprintf(message, message_size, "killed by signal %d (%s)", WTERMSIG(status), strsignal(WTERMSIG(status)));
+    } else {
+        snprintf(message, message_size, "exited with code %d", WEXITSTATUS(status));
+    }
+    return 0;
+}
+
+int main(void) {
+    static struct kerat_buffer output;
+    int success = 1;
+
+    fputs("{\"message\":\"\",\"output\":[", stdout);
+    for (int i = 0; i < kerat_test_count; i++) {
+        char message[1024] = "";
+        output.len = 0;
+        output.data[0] = '\0';
+
+        int passed = kerat_run(&kerat_tests[i], message, sizeof(message), &output);
+        success = success && passed;
+
+        if (i > 0) {
+            putchar(',');
+        }
+        printf("{\"passed\":%s,\"name\":", passed ? "true" : "false");
+        kerat_print_json(kerat_tests[i].name);
+        fputs(",\"message\":", stdout);
+        kerat_print_json(message);
+        fputs(",\"stack_trace\":", stdout);
+        kerat_print_json(passed ? "" : output.data);
+        putchar('}');
+    }
+    printf("],\"success\":%s}\n", success ? "true" : "false");
+
+    return 0;
+}
+
+#endif /* KERAT_IMPLEMENTATION */
+
+#endif /* KERAT_H */
@@ -0,0 +1,2 @@
+#define KERAT_IMPLEMENTATION
+#include "kerat.h"
@@ -1,5 +1,8 @@
 using Xunit.Runners;
+using Xunit.Abstractions;
 using System.Reflection;
+using System.Diagnostics;
+using System.Globalization;
 using System.Text.Json;
 using System.Collections.Concurrent;
 using System.Security.Cryptography;
@@ -7,8 +10,15 @@ using System.Text.Json.Serialization;
 
 public class Program
 {
-    public static void Main()
+    // printed by a test process once the runtime is up and the test is found
+    const string Ready = "ready";
+
+    // left of the container timeout to print the results before being killed
+    static readonly TimeSpan ResultMargin = TimeSpan.FromSeconds(2);
+
+    public static void Main(string[] args)
     {
+        var started = Stopwatch.StartNew();
         string assemblyPath = RandomNumberGenerator.GetHexString(8, true) + ".dll";
 
         string dir = AppContext.BaseDirectory;
@@ -32,27 +42,98 @@ public class Program
             return Assembly.Load(args.Name);
         };
 
-        var stack = new ConcurrentStack<TestResult>();
+        // a lone test case runs in its own process, so a hanging one can be killed
+        if (args.Length == 2 && args[0] == "--test")
+        {
+            // whatever the test prints must not end up in the result
+            var stdout = Console.Out;
+            Console.SetOut(Console.Error);
+
+            // startup does not count against the test timeout, it is slow next to most tests
+            var results = Execute(tc => tc.UniqueID == args[1], () =>
+            {
+                stdout.WriteLine(Ready);
+                stdout.Flush();
+            });
+            stdout.WriteLine(JsonSerializer.Serialize(results));
+            return;
+        }
+
+        var timeout = Seconds("KERAT_TEST_TIMEOUT");
+
+        // every test process starts a runtime of its own, together they may not
+        // fit in the container timeout, tests out of time are reported instead
+        var budget = Seconds("KERAT_TIMEOUT");
+        if (budget != Timeout.InfiniteTimeSpan)
+        {
+            budget -= ResultMargin;
+        }
+
+        // discover only, every test case is filtered out from execution
+        var cases = new ConcurrentQueue<(string Id, string Name)>();
+        Execute(tc =>
+        {
+            cases.Enqueue((tc.UniqueID, tc.DisplayName));
+            return false;
+        }, null);
+
+        var testResult = new List<TestResult>();
+        foreach (var tc in cases)
+        {
+            List<TestResult> results = [OutOfTime(tc.Name, TimeSpan.Zero)];
+            if (budget == Timeout.InfiniteTimeSpan)
+            {
+                results = Isolate(tc.Id, tc.Name, timeout, budget);
+            }
+            else if (budget > started.Elapsed)
+            {
+                results = Isolate(tc.Id, tc.Name, timeout, budget - started.Elapsed);
+            }
+
+            foreach (var result in results)
+            {
+                // streamed right away, the whole run is still printed at the end
+                Console.WriteLine(JsonSerializer.Serialize(new { test = result }));
+                testResult.Add(result);
+            }
+        }
+
+        var res = new ContainerResult
+        {
+            Success = testResult.All(el => el.Passed),
+            Output = testResult,
+        };
+
+        Console.WriteLine(JsonSerializer.Serialize(res));
+    }
+
+    static List<TestResult> Execute(Func<ITestCase, bool> filter, Action? discovered)
+    {
+        var results = new ConcurrentQueue<TestResult>();
 
         string exec = typeof(Program).Assembly?.Location ?? "";
         using var completionEvent = new ManualResetEventSlim(false);
         using var runner = AssemblyRunner.WithoutAppDomain(exec);
+        runner.TestCaseFilter = filter;
+        runner.OnDiscoveryComplete = _ => discovered?.Invoke();
         runner.OnTestFailed = info =>
         {
-            stack.Push(new TestResult
+            results.Enqueue(new TestResult
             {
                 Passed = false,
                 Name = info.TestDisplayName,
                 StackTrace = string.IsNullOrEmpty(info.ExceptionMessage) ? info.ExceptionStackTrace : info.ExceptionMessage,
+                Duration = (double)info.ExecutionTime,
             });
         };
 
         runner.OnTestPassed = info =>
         {
-            stack.Push(new TestResult
+            results.Enqueue(new TestResult
             {
                 Passed = true,
                 Name = info.TestDisplayName,
+                Duration = (double)info.ExecutionTime,
             });
         };
 
@@ -65,16 +146,124 @@ public class Program
 
         completionEvent.Wait();
 
-        var testResult = stack.ToArray();
+        return results.ToList();
+    }
 
-        var res = new ContainerResult 
+    // run a test case in a child process, killed once it is over timeout
+    // or over what is left of the whole run
+    static List<TestResult> Isolate(string id, string name, TimeSpan timeout, TimeSpan remaining)
+    {
+        var info = new ProcessStartInfo(Environment.ProcessPath ?? "")
         {
-            Success = testResult.All(el => el.Passed),
-            Output = testResult,
+            RedirectStandardOutput = true,
+            RedirectStandardError = true,
         };
+        info.ArgumentList.Add("--test");
+        info.ArgumentList.Add(id);
 
-        Console.Clear();
-        Console.WriteLine(JsonSerializer.Serialize(res));
+        var watch = Stopwatch.StartNew();
+        using var process = Process.Start(info) ?? throw new Exception("failed to start test process");
+        var stderr = process.StandardError.ReadToEndAsync();
+
+        // null when it crashed on the way, reported below
+        var ready = process.StandardOutput.ReadLineAsync();
+        if (!ready.Wait(remaining))
+        {
+            process.Kill(true);
+            process.WaitForExit();
+            return [OutOfTime(name, watch.Elapsed)];
+        }
+        var stdout = process.StandardOutput.ReadToEndAsync();
+
+        if (remaining != Timeout.InfiniteTimeSpan)
+        {
+            remaining = remaining > watch.Elapsed ? remaining - watch.Elapsed : TimeSpan.Zero;
+        }
+
+        var limit = Shorter(timeout, remaining);
+        var testWatch = Stopwatch.StartNew();
+        if (!process.WaitForExit(limit))
+        {
+            process.Kill(true);
+            process.WaitForExit();
+
+            if (limit != timeout)
+            {
+                return [OutOfTime(name, watch.Elapsed)];
+            }
+
+            return [new TestResult
+            {
+                Passed = false,
+                Name = name,
+                Verdict = "time_limit_exceeded",
+                Message = $"test exceeded time limit of {timeout.TotalSeconds}s",
+                Duration = testWatch.Elapsed.TotalSeconds,
+            }];
+        }
+
+        // waits for redirected streams as well
+        process.WaitForExit();
+
+        try
+        {
+            var results = JsonSerializer.Deserialize<List<TestResult>>(stdout.Result);
+            if (results != null && results.Count > 0)
+            {
+                return results;
+            }
+        }
+        catch (JsonException)
+        {
+        }
+
+        // crashed before reporting, e.g. stack overflow
+        return [new TestResult
+        {
+            Passed = false,
+            Name = name,
+            Message = $"test process exited with code {process.ExitCode}",
+            StackTrace = stderr.Result,
+            Duration = watch.Elapsed.TotalSeconds,
+        }];
+    }
+
+    static TestResult OutOfTime(string name, TimeSpan elapsed)
+    {
+        return new TestResult
+        {
+            Passed = false,
+            Name = name,
+            Verdict = "time_limit_exceeded",
+            Message = "submission ran out of time before the test finished",
+            Duration = elapsed.TotalSeconds,
+        };
+    }
+
+    // infinite when unset
+    static TimeSpan Seconds(string name)
+    {
+        if (double.TryParse(Environment.GetEnvironmentVariable(name), NumberStyles.Float, CultureInfo.InvariantCulture, out double seconds) && seconds > 0)
+        {
+            return TimeSpan.FromSeconds(seconds);
+        }
+
+        return Timeout.InfiniteTimeSpan;
+    }
+
+    static TimeSpan Shorter(TimeSpan a, TimeSpan b)
+    {
+        if (a == Timeout.InfiniteTimeSpan)
+        {
+            return b;
+        }
+
+        if (b == Timeout.InfiniteTimeSpan)
+        {
+            return a;
+        }
+
+        return a < b ? a : b;
     }
 }
 
@@ -103,4 +292,11 @@ public class TestResult
 
     [JsonPropertyName("stack_trace")]
     public string StackTrace { get; set; } = "";
+
+    [JsonPropertyName("verdict")]
+    public string Verdict { get; set; } = "";
+
+    // seconds
+    [JsonPropertyName("duration")]
+    public double Duration { get; set; } = 0;
 }
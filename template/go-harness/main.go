@@ -0,0 +1,174 @@
+// kerat Go harness, runs the submission test binary through test2json
+// and turns its run, pass, fail and skip events into a test result per test
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+const (
+	testBinary = "/workspace/box"
+	test2json  = "/kerat/test2json"
+)
+
+// event is emitted by test2json, see `go doc test2json`
+type event struct {
+	Action string `json:"Action"`
+	Test   string `json:"Test"`
+	Output string `json:"Output"`
+}
+
+type testResult struct {
+	Passed     bool   `json:"passed"`
+	Name       string `json:"name"`
+	Message    string `json:"message"`
+	StackTrace string `json:"stack_trace"`
+}
+
+type run struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Output  []testResult `json:"output"`
+}
+
+func main() {
+	// os.Exit(0) from a test or init would otherwise end the run as if it passed
+	cmd := exec.Command(test2json, testBinary, "-test.v=test2json", "-test.paniconexit0")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		exit("failed to run tests: " + err.Error())
+	}
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		exit("failed to run tests: " + err.Error())
+	}
+
+	res := collect(stdout)
+
+	// failing tests exit non-zero as well, results tell what happened
+	cmd.Wait()
+
+	report(res)
+}
+
+func collect(r io.Reader) run {
+	res := run{Success: true, Output: []testResult{}}
+
+	outputs := map[string]*strings.Builder{}
+	var pkgOutput strings.Builder
+
+	// tests started but not finished yet, in the order they started
+	var running []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ev event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			// not an event, e.g. binary crashed before the first test
+			pkgOutput.WriteString(scanner.Text() + "\n")
+			continue
+		}
+
+		if ev.Test == "" {
+			if ev.Action == "output" {
+				pkgOutput.WriteString(ev.Output)
+			} else if ev.Action == "fail" {
+				res.Success = false
+			}
+			continue
+		}
+
+		switch ev.Action {
+		case "run":
+			outputs[ev.Test] = &strings.Builder{}
+			running = append(running, ev.Test)
+		case "pass", "fail", "skip":
+			running = slices.DeleteFunc(running, func(name string) bool { return name == ev.Test })
+		}
+
+		switch ev.Action {
+		case "output":
+			if out, ok := outputs[ev.Test]; ok && !isFraming(ev.Output) {
+				out.WriteString(strings.TrimSpace(ev.Output) + "\n")
+			}
+		case "pass", "skip":
+			res.Output = append(res.Output, stream(testResult{Passed: true, Name: ev.Test}))
+		case "fail":
+			res.Success = false
+			message := ""
+			if out, ok := outputs[ev.Test]; ok {
+				message = strings.TrimSpace(out.String())
+			}
+			res.Output = append(res.Output, stream(testResult{Passed: false, Name: ev.Test, Message: message}))
+		}
+	}
+
+	// binary stopped in the middle of these, e.g. crashed or killed
+	for _, name := range running {
+		message := "test did not finish"
+		if out := strings.TrimSpace(outputs[name].String()); out != "" {
+			message = out + "\n" + message
+		}
+		res.Success = false
+		res.Output = append(res.Output, stream(testResult{Passed: false, Name: name, Message: message}))
+	}
+
+	// package failed without blaming any test, e.g. panic in init or TestMain
+	if !res.Success && !hasFailure(res.Output) {
+		res.Message = strings.TrimSpace(pkgOutput.String())
+	}
+
+	// nothing to grade, reported as harness error
+	if len(res.Output) == 0 {
+		res.Success = false
+		res.Message = strings.TrimSpace("no test results were collected\n" + pkgOutput.String())
+	}
+
+	return res
+}
+
+// lines test2json already turned into actions
+func isFraming(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, prefix := range []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME", "--- PASS", "--- FAIL", "--- SKIP"} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFailure(results []testResult) bool {
+	for _, v := range results {
+		if !v.Passed {
+			return true
+		}
+	}
+	return false
+}
+
+// stream reports a completed test right away, it is part of the final report as well
+func stream(test testResult) testResult {
+	json.NewEncoder(os.Stdout).Encode(struct {
+		Test testResult `json:"test"`
+	}{test})
+
+	return test
+}
+
+func report(res run) {
+	json.NewEncoder(os.Stdout).Encode(res)
+}
+
+func exit(msg string) {
+	report(run{Success: false, Message: msg, Output: []testResult{}})
+	os.Exit(0)
+}
@@ -0,0 +1,183 @@
+import java.io.IOException;
+import java.io.PrintStream;
+import java.util.ArrayList;
+import java.util.Collections;
+import java.util.List;
+import java.util.jar.JarFile;
+
+import org.junit.platform.engine.TestExecutionResult;
+import org.junit.platform.engine.TestSource;
+import org.junit.platform.engine.discovery.ClassSelector;
+import org.junit.platform.engine.discovery.DiscoverySelectors;
+import org.junit.platform.engine.support.descriptor.MethodSource;
+import org.junit.platform.launcher.Launcher;
+import org.junit.platform.launcher.LauncherDiscoveryRequest;
+import org.junit.platform.launcher.TestExecutionListener;
+import org.junit.platform.launcher.TestIdentifier;
+import org.junit.platform.launcher.core.LauncherDiscoveryRequestBuilder;
+import org.junit.platform.launcher.core.LauncherFactory;
+
+// runs every JUnit test class inside the submission jar through the JUnit
+// Platform launcher, tests are named Class.method
+public class KeratRunner {
+    static final String SUBMISSION = "/workspace/box";
+
+    public static void main(String[] args) {
+        // stdout is reserved for results, anything printed by submission goes to stderr
+        PrintStream out = System.out;
+        System.setOut(System.err);
+
+        List<ClassSelector> selectors;
+        try {
+            selectors = classes(SUBMISSION);
+        } catch (IOException e) {
+            out.println(run(false, "failed to read submission: " + e.getMessage(), List.of()));
+            return;
+        }
+
+        LauncherDiscoveryRequest request = LauncherDiscoveryRequestBuilder.request()
+                .selectors(selectors)
+                .build();
+
+        Collector collector = new Collector(out);
+        Launcher launcher = LauncherFactory.create();
+        launcher.execute(request, collector);
+
+        boolean success = collector.results.stream().allMatch(r -> r.passed);
+        out.println(run(success, "", collector.results));
+    }
+
+    // jar is named box, JUnit classpath scanning only picks up *.jar files
+    static List<ClassSelector> classes(String path) throws IOException {
+        List<ClassSelector> selectors = new ArrayList<>();
+        try (JarFile jar = new JarFile(path)) {
+            jar.stream()
+                    .map(entry -> entry.getName())
+                    .filter(name -> name.endsWith(".class") && !name.contains("$"))
+                    .map(name -> name.substring(0, name.length() - ".class".length()).replace('/', '.'))
+                    .forEach(name -> selectors.add(DiscoverySelectors.selectClass(name)));
+        }
+        return selectors;
+    }
+
+    static class Result {
+        boolean passed;
+        String name;
+        String message = "";
+        String stackTrace = "";
+    }
+
+    // every result is streamed as a {"test": ...} line once the test is done
+    static class Collector implements TestExecutionListener {
+        final List<Result> results = Collections.synchronizedList(new ArrayList<>());
+        final PrintStream out;
+
+        Collector(PrintStream out) {
+            this.out = out;
+        }
+
+        synchronized void add(Result res) {
+            results.add(res);
+            out.println("{\"test\":" + json(res) + "}");
+            out.flush();
+        }
+
+        @Override
+        public void executionSkipped(TestIdentifier id, String reason) {
+            if (id.isTest()) {
+                Result res = new Result();
+                res.passed = true;
+                res.name = name(id);
+                add(res);
+            }
+        }
+
+        @Override
+        public void executionFinished(TestIdentifier id, TestExecutionResult result) {
+            boolean passed = result.getStatus() == TestExecutionResult.Status.SUCCESSFUL;
+
+            // a failing container (e.g. @BeforeAll) never runs its tests
+            if (!id.isTest() && passed) {
+                return;
+            }
+
+            Result res = new Result();
+            res.passed = passed;
+            res.name = name(id);
+            result.getThrowable().ifPresent(t -> {
+                res.message = t.getMessage() == null ? t.getClass().getName() : t.getMessage();
+                res.stackTrace = stackTrace(t);
+            });
+            add(res);
+        }
+    }
+
+    static String name(TestIdentifier id) {
+        TestSource source = id.getSource().orElse(null);
+        if (source instanceof MethodSource method) {
+            String className = method.getClassName();
+            String simpleName = className.substring(className.lastIndexOf('.') + 1);
+            return simpleName + "." + method.getMethodName();
+        }
+        return id.getDisplayName();
+    }
+
+    // skip JUnit, JDK and runner frames, reflection makes up most of a JUnit trace
+    static String stackTrace(Throwable t) {
+        StringBuilder sb = new StringBuilder(t.toString());
+        for (StackTraceElement frame : t.getStackTrace()) {
+            String cls = frame.getClassName();
+            if (cls.startsWith("org.junit.") || cls.startsWith("java.") || cls.startsWith("jdk.")
+                    || cls.startsWith("sun.") || cls.startsWith("KeratRunner")) {
+                continue;
+            }
+            sb.append("\n    at ").append(frame);
+        }
+        return sb.toString();
+    }
+
+    static String run(boolean success, String message, List<Result> results) {
+        StringBuilder sb = new StringBuilder();
+        sb.append("{\"success\":").append(success);
+        sb.append(",\"message\":").append(quote(message));
+        sb.append(",\"output\":[");
+        for (int i = 0; i < results.size(); i++) {
+            Result r = results.get(i);
+            if (i > 0) {
+                sb.append(',');
+            }
+            sb.append(json(r));
+        }
+        sb.append("]}");
+        return sb.toString();
+    }
+
+    static String json(Result r) {
+        return "{\"passed\":" + r.passed
+                + ",\"name\":" + quote(r.name)
+                + ",\"message\":" + quote(r.message)
+                + ",\"stack_trace\":" + quote(r.stackTrace)
+                + "}";
+    }
+
+    static String quote(String s) {
+        StringBuilder sb = new StringBuilder("\"");
+        for (char c : s.toCharArray()) {
+            switch (c) {
+                case '"' -> sb.append("\\\"");
+                case '\\' -> sb.append("\\\\");
+                case '\n' -> sb.append("\\n");
+                case '\r' -> sb.append("\\r");
+                case '\t' -> sb.append("\\t");
+                default -> {
+                    if (c < 0x20) {
+                        sb.append(String.format("\\u%04x", (int) c));
+                    } else {
+                        sb.append(c);
+                    }
+                }
+            }
+        }
+        return sb.append('"').toString();
+    }
+}
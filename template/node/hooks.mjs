@@ -0,0 +1,35 @@
+// on-the-fly TypeScript transpilation, types are not checked
+import ts from "typescript";
+import { readFile } from "node:fs/promises";
+import { fileURLToPath } from "node:url";
+
+const compilerOptions = {
+  module: ts.ModuleKind.ESNext,
+  target: ts.ScriptTarget.ES2022,
+  inlineSourceMap: true,
+  inlineSources: true,
+};
+
+export async function resolve(specifier, context, next) {
+  try {
+    return await next(specifier, context);
+  } catch (err) {
+    // TS sources import each other as ./example or ./example.js
+    if (err.code === "ERR_MODULE_NOT_FOUND" && specifier.startsWith(".")) {
+      return next(specifier.replace(/\.js$/, "") + ".ts", context);
+    }
+    throw err;
+  }
+}
+
+export async function load(url, context, next) {
+  if (!url.endsWith(".ts")) {
+    return next(url, context);
+  }
+
+  const filename = fileURLToPath(url);
+  const source = await readFile(filename, "utf8");
+  const { outputText } = ts.transpileModule(source, { fileName: filename, compilerOptions });
+
+  return { format: "module", source: outputText, shortCircuit: true };
+}
@@ -0,0 +1,96 @@
+import { run } from "node:test";
+import { readdirSync } from "node:fs";
+import { basename, join } from "node:path";
+
+const workdir = "/workspace";
+const testFile = /\.test\.(js|mjs|ts)$/;
+
+function exit(message) {
+  console.log(JSON.stringify({ success: false, message, output: [] }));
+  process.exit(0);
+}
+
+// keep frames pointing into the workdir, node internals and node:test frames are dropped
+function stackTrace(err) {
+  if (!err?.stack) {
+    return "";
+  }
+
+  return err.stack
+    .split("\n")
+    .filter((line) => line.includes(workdir))
+    .map((line) => line.trim().replace("file://", ""))
+    .join("\n");
+}
+
+function failure(details) {
+  // node:test wraps what was thrown by the test
+  const err = details?.error?.cause ?? details?.error;
+  if (err === undefined || err === null) {
+    return { message: "", stack_trace: "" };
+  }
+
+  const message = typeof err === "object" && "message" in err ? String(err.message) : String(err);
+  return { message, stack_trace: stackTrace(err) };
+}
+
+async function main() {
+  let files;
+  try {
+    files = readdirSync(workdir)
+      .filter((name) => testFile.test(name))
+      .sort()
+      .map((name) => join(workdir, name));
+  } catch (err) {
+    exit(`failed to read tests: ${err.message}`);
+  }
+
+  const output = [];
+  const path = [];
+
+  // streamed right away, the whole run is still printed at the end
+  const report = (test) => {
+    output.push(test);
+    console.log(JSON.stringify({ test }));
+  };
+  const stderr = {};
+
+  // every file runs in its own process, anything it prints never reaches our stdout
+  const stream = run({ files, concurrency: 1 });
+  for await (const event of stream) {
+    const data = event.data;
+    switch (event.type) {
+      case "test:start":
+        path[data.nesting] = data.name;
+        break;
+
+      case "test:stderr":
+        stderr[data.file] = (stderr[data.file] ?? "") + data.message;
+        break;
+
+      case "test:pass":
+      case "test:fail": {
+        if (data.details?.type === "suite") {
+          break;
+        }
+
+        const name = [...path.slice(0, data.nesting), data.name].join(" > ");
+        if (event.type === "test:pass") {
+          report({ passed: true, name, message: "", stack_trace: "" });
+        } else if (data.name === data.file) {
+          // the file itself failed, e.g. syntax error, the reason is in its stderr
+          const message = stderr[data.file]?.trim() || failure(data.details).message;
+          report({ passed: false, name: basename(data.file), message, stack_trace: "" });
+        } else {
+          report({ passed: false, name, ...failure(data.details) });
+        }
+        break;
+      }
+    }
+  }
+
+  const success = output.length > 0 && output.every((test) => test.passed);
+  console.log(JSON.stringify({ success, message: "", output }));
+}
+
+main().catch((err) => exit(`harness error: ${err.message}`));
@@ -0,0 +1,4 @@
+// loaded with --import, test files run in child processes which inherit it
+import { register } from "node:module";
+
+register("./hooks.mjs", import.meta.url);
@@ -1,3 +1,4 @@
+import os
 import sys
 import json
 import unittest
@@ -13,8 +14,10 @@ def run_tests(filenames: Sequence[str], dir: Path):
 
     loader = unittest.TestLoader()
     suite = loader.loadTestsFromNames(filenames)
-    runner = KeratTestRunner()
+    # seconds per test, unbounded when not set
+    timeout = float(os.environ.get("KERAT_TEST_TIMEOUT", "0") or 0)
+    runner = KeratTestRunner(timeout=timeout)
 
     res = runner.run(suite)
-    res = Run("", True, res)
+    res = Run("", all(v.passed for v in res), res)
     print(json.dumps(asdict(res)))
@@ -20,6 +20,8 @@ class TestResult:
     name: str
     message: str
     stack_trace: str
+    verdict: str = ""
+    duration: float = 0.0
 
 
 @dataclass
@@ -1,7 +1,11 @@
+import json
 import unittest
 import traceback
-from typing import List
+import multiprocessing
+from time import perf_counter
+from typing import Iterator, List
 from pathlib import Path
+from dataclasses import asdict
 from model import TestResult
 
 
@@ -12,8 +16,10 @@ class KeratTestResult(unittest.TestResult):
 
     def startTest(self, test):
         self.current_test = TestResult(True, test._testMethodName, "", "")
+        self.started = perf_counter()
 
     def stopTest(self, test):
+        self.current_test.duration = perf_counter() - self.started
         self.results.append(self.current_test)
 
     def addError(self, test, err):
@@ -28,13 +34,76 @@ class KeratTestResult(unittest.TestResult):
         self.current_test.message = "".join(traceback.format_exception_only(exc_type, exc_value))
 
 
+def flatten(suite) -> Iterator[unittest.TestCase]:
+    for test in suite:
+        if isinstance(test, unittest.TestSuite):
+            yield from flatten(test)
+        else:
+            yield test
+
+
+def isolated(test, conn):
+    """runs in the forked child, class and module fixtures are set up for the lone test"""
+    result = KeratTestResult()
+    try:
+        unittest.TestSuite([test]).run(result)
+    finally:
+        conn.send(result.results)
+        conn.close()
+
 
 class KeratTestRunner:
-    def __init__(self, failfast=False):
+    def __init__(self, failfast=False, timeout: float = 0):
         self.failfast = failfast
+        self.timeout = timeout
 
     def run(self, test) -> List[TestResult]:
-        result = KeratTestResult()
-        result.failfast = self.failfast
-        test.run(result)
-        return result.results
+        results: List[TestResult] = []
+        for case in flatten(test):
+            for result in self.isolate(case):
+                # streamed as soon as it is done, the whole run is still printed at the end
+                print(json.dumps({"test": asdict(result)}), flush=True)
+                results.append(result)
+
+            if self.failfast and not all(v.passed for v in results):
+                break
+
+        return results
+
+    def isolate(self, test) -> List[TestResult]:
+        """runs a test in a forked child, killed once it is over timeout,
+        so neither a hanging nor a crashing test takes the rest with it"""
+        ctx = multiprocessing.get_context("fork")
+        receiver, sender = ctx.Pipe(duplex=False)
+        process = ctx.Process(target=isolated, args=(test, sender))
+
+        started = perf_counter()
+        process.start()
+        sender.close()
+
+        # received before joining, the child blocks until a large result is read
+        results: List[TestResult] = []
+        timed_out = not receiver.poll(self.timeout if self.timeout > 0 else None)
+        if timed_out:
+            process.kill()
+        else:
+            try:
+                results = receiver.recv()
+            except EOFError:
+                pass
+
+        process.join()
+        receiver.close()
+        duration = perf_counter() - started
+
+        name = getattr(test, "_testMethodName", str(test))
+        if timed_out:
+            message = f"test exceeded time limit of {self.timeout:g}s"
+            return [TestResult(False, name, message, "", "time_limit_exceeded", duration)]
+
+        # crashed before reporting, e.g. os._exit or a fault in an extension
+        if not results:
+            message = f"test process exited with code {process.exitcode}"
+            return [TestResult(False, name, message, "", "", duration)]
+
+        return results
@@ -0,0 +1,16 @@
+#!/bin/sh
+# builds exercise tests into a single libtest binary, every *_test.rs becomes a module of it
+set -e
+
+: > kerat_tests.rs
+for f in *_test.rs; do
+    [ -e "$f" ] || continue
+    printf '#[path = "%s"]\nmod %s;\n' "$f" "${f%.rs}" >> kerat_tests.rs
+done
+
+# diagnostics are rendered to stderr, stdout only carries build messages
+cargo test --offline --test kerat --no-run --message-format=json-render-diagnostics > /tmp/cargo.json
+
+executable=$(sed -n 's/.*"executable":"\([^"]*\)".*/\1/p' /tmp/cargo.json | tail -n 1)
+mkdir -p output
+cp "$executable" output/box
@@ -0,0 +1,186 @@
+// kerat Rust harness, runs the submission libtest binary and scrapes its
+// plain text output, libtest json output is still unstable
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const testBinary = "/workspace/box"
+
+type testResult struct {
+	Passed     bool   `json:"passed"`
+	Name       string `json:"name"`
+	Message    string `json:"message"`
+	StackTrace string `json:"stack_trace"`
+}
+
+type run struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Output  []testResult `json:"output"`
+}
+
+func main() {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(testBinary, "--color", "never")
+	cmd.Stdout = io.MultiWriter(&stdout, &progress{})
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "RUST_BACKTRACE=0")
+
+	// failing tests exit non-zero as well, output tells what happened
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		exit("failed to run tests: " + err.Error())
+	}
+
+	res := parse(stdout.String())
+
+	// binary died before libtest could summarize, e.g. stack overflow or abort
+	if !strings.Contains(stdout.String(), "\ntest result: ") {
+		res.Success = false
+		res.Message = strings.TrimSpace(stderr.String())
+	}
+
+	report(res)
+}
+
+// progress streams tests as libtest reports them, failure details only
+// come with the summary, they are part of the final report
+type progress struct {
+	line []byte
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.line = append(p.line, b...)
+	for {
+		line, rest, ok := bytes.Cut(p.line, []byte("\n"))
+		if !ok {
+			break
+		}
+
+		if name, status, ok := testLine(string(line)); ok {
+			stream(testResult{Passed: status == "ok" || status == "ignored", Name: name})
+		}
+		p.line = rest
+	}
+
+	return len(b), nil
+}
+
+// parse reads libtest text output, e.g.
+//
+//	test add_test::adds ... FAILED
+//	...
+//	---- add_test::adds stdout ----
+//	thread 'add_test::adds' panicked at add_test.rs:5:5:
+//	assertion `left == right` failed
+func parse(output string) run {
+	res := run{Success: true, Output: []testResult{}}
+	index := map[string]int{}
+
+	var current string
+	var block []string
+	flush := func() {
+		if i, ok := index[current]; ok {
+			res.Output[i].Message, res.Output[i].StackTrace = failure(block)
+		}
+		current, block = "", nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if name, status, ok := testLine(line); ok {
+			passed := status == "ok" || status == "ignored"
+			res.Success = res.Success && passed
+			index[name] = len(res.Output)
+			res.Output = append(res.Output, testResult{Passed: passed, Name: name})
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(line, "---- "); ok && strings.HasSuffix(name, " stdout ----") {
+			flush()
+			current = strings.TrimSuffix(name, " stdout ----")
+			continue
+		}
+
+		// end of failure blocks
+		if current != "" && (line == "failures:" || strings.HasPrefix(line, "test result: ")) {
+			flush()
+			continue
+		}
+
+		if current != "" {
+			block = append(block, line)
+		}
+	}
+	flush()
+
+	return res
+}
+
+// test <name> ... <status>, status may be followed by timing
+func testLine(line string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(line, "test ")
+	if !ok {
+		return "", "", false
+	}
+
+	name, status, ok := strings.Cut(rest, " ... ")
+	if !ok {
+		return "", "", false
+	}
+
+	status, _, _ = strings.Cut(status, " ")
+	switch status {
+	case "ok", "FAILED", "ignored":
+		return name, status, true
+	}
+
+	return "", "", false
+}
+
+// panic location goes to stack trace, panic message and captured output to message
+func failure(block []string) (string, string) {
+	var message []string
+	var location string
+	for _, line := range block {
+		if strings.HasPrefix(line, "note: run with `RUST_BACKTRACE") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "thread '") && strings.Contains(line, "' panicked at ") {
+			_, at, _ := strings.Cut(line, "' panicked at ")
+			location = strings.TrimSuffix(at, ":")
+			continue
+		}
+
+		message = append(message, line)
+	}
+
+	return strings.TrimSpace(strings.Join(message, "\n")), location
+}
+
+func stream(test testResult) {
+	json.NewEncoder(os.Stdout).Encode(struct {
+		Test testResult `json:"test"`
+	}{test})
+}
+
+func report(res run) {
+	json.NewEncoder(os.Stdout).Encode(res)
+}
+
+func exit(msg string) {
+	report(run{Success: false, Message: msg, Output: []testResult{}})
+	os.Exit(0)
+}
@@ -12,6 +12,11 @@ func IsNotExist(dir string) bool {
 	return errors.Is(err, fs.ErrNotExist)
 }
 
+// IsValidFilename reports whether name is a plain file name without any directory
+func IsValidFilename(name string) bool {
+	return name != "" && name != "." && name != ".." && filepath.Base(name) == name
+}
+
 func GetFilenames(dir string, filenames []string) ([]string, error) {
 	dirs, err := os.ReadDir(dir)
 	if err != nil {
@@ -32,3 +37,71 @@ func GetFilenames(dir string, filenames []string) ([]string, error) {
 
 	return filenames, nil
 }
+
+// DirSize sums size of regular files under dir
+func DirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+
+		return nil
+	})
+
+	return size, err
+}
+
+// LinkTree mirrors src into dst with hard links, falls back to copy across filesystems.
+// Linked files share content with src, replace them instead of writing in place
+func LinkTree(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if err := os.Link(path, target); err == nil {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(target, content, 0644)
+	})
+}
+
+// ReplaceFile writes content to a new inode, leaving hard linked copies intact
+func ReplaceFile(path string, content []byte, perm fs.FileMode) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return os.WriteFile(path, content, perm)
+}